@@ -58,7 +58,7 @@ func ShowUsage() {
 Where command is one of the following:
   init <agentname> <serverhost> <serverport> [server cert fingerprint]   Create config file
   ping                                                                   Ping the configured whazza server
-  run 																	 Run the agent continously
+  run 																	 Run the agent continously, reloading checks.json when it changes or on SIGHUP
 `, os.Args[0])
 }
 
@@ -78,6 +78,14 @@ func checksConfigFile() string {
 	return path.Join(dir, "checks.json")
 }
 
+func queueFile() string {
+	dir := os.Getenv("WHAZZA_AGENT_DIR")
+	if dir == "" {
+		dir = path.Join(os.Getenv("HOME"), ".whazza-agent")
+	}
+	return path.Join(dir, "queue.jsonl")
+}
+
 func readConf() agent.Config {
 	file := configFile()
 	cfg, err := agent.ReadConfig(file)
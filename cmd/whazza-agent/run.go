@@ -2,9 +2,12 @@ package main
 
 import (
 	"container/heap"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/rymdhund/whazza/internal/agent"
@@ -36,6 +39,17 @@ func (pq *PriorityQueue) Push(x interface{}) {
 	*pq = append(*pq, item)
 }
 
+// find returns the scheduled check with the same key as check, which may have a new interval or thresholds
+func (pq PriorityQueue) find(check chk.Check) (chk.Check, bool) {
+	key := agent.CheckKey(check)
+	for _, tc := range pq {
+		if agent.CheckKey(tc.check) == key {
+			return tc.check, true
+		}
+	}
+	return chk.Check{}, false
+}
+
 func (pq *PriorityQueue) Pop() interface{} {
 	old := *pq
 	n := len(old)
@@ -45,6 +59,75 @@ func (pq *PriorityQueue) Pop() interface{} {
 	return item
 }
 
+// How often checks.json is checked for changes
+const checksWatchInterval = 10 * time.Second
+
+// loadChecks reads and validates the checks config file
+func loadChecks() ([]chk.Check, error) {
+	f, err := os.Open(checksConfigFile())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	checks, err := agent.ParseChecksConfig(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(checks) == 0 {
+		return nil, errors.New("No checks to run")
+	}
+	return checks, nil
+}
+
+// watchFile signals on changed when the modification time or size of a file changes
+func watchFile(filename string, interval time.Duration, changed chan<- struct{}) {
+	var modTime time.Time
+	var size int64
+	if info, err := os.Stat(filename); err == nil {
+		modTime, size = info.ModTime(), info.Size()
+	}
+	for {
+		time.Sleep(interval)
+		info, err := os.Stat(filename)
+		if err != nil {
+			continue
+		}
+		if !info.ModTime().Equal(modTime) || info.Size() != size {
+			modTime, size = info.ModTime(), info.Size()
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// reloadChecks replaces the scheduled checks. Unchanged checks keep their next run.
+func reloadChecks(pq *PriorityQueue, checks []chk.Check, now time.Time) {
+	scheduled := map[string]time.Time{}
+	for _, tc := range *pq {
+		scheduled[agent.CheckKey(tc.check)] = tc.time
+	}
+	added := 0
+	kept := map[string]bool{}
+	for _, c := range checks {
+		key := agent.CheckKey(c)
+		if _, ok := scheduled[key]; ok {
+			kept[key] = true
+		} else {
+			added++
+		}
+	}
+
+	next := agent.ReloadSchedule(scheduled, checks, now)
+	*pq = make(PriorityQueue, len(checks))
+	for i, c := range checks {
+		(*pq)[i] = &timedCheck{time: next[i], check: c}
+	}
+	heap.Init(pq)
+	InfoLog.Printf("Reloaded checks, %d added, %d removed, %d kept", added, len(scheduled)-len(kept), len(kept))
+}
+
 func run() {
 	DebugLog = log.New(os.Stdout, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
 	InfoLog = log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
@@ -52,53 +135,115 @@ func run() {
 	ErrorLog = log.New(os.Stdout, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
 
 	cfg := readConf()
-	f, err := os.Open(checksConfigFile())
+	checks, err := loadChecks()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading checks.json file: %s\n", err)
 		os.Exit(1)
 	}
 
-	checks, err := agent.ParseChecksConfig(f)
+	hubConn, err := agent.NewHubConnection(cfg)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading checks.json file: %s\n", err)
+		fmt.Fprintf(os.Stderr, "Error creating connection to hub: %s\n", err)
 		os.Exit(1)
 	}
-	f.Close()
+	checkContext := chk.NewContext()
 
-	hubConn, err := agent.NewHubConnection(cfg)
+	queue, err := agent.OpenResultQueue(queueFile(), cfg.QueueSize)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating connection to hub: %s\n", err)
+		fmt.Fprintf(os.Stderr, "Error opening result queue: %s\n", err)
 		os.Exit(1)
 	}
-	checkContext := chk.NewContext()
+	if n := queue.Len(); n > 0 {
+		InfoLog.Printf("Resending %d queued results", n)
+	}
+	sender := agent.NewSender(func(msgs []messages.CheckResultMsg) ([]messages.ResultStatusMsg, error) {
+		return hubConn.SendCheckResults(cfg, msgs)
+	}, queue)
+	// Let the hub retire removed checks and continue the schedule from when the checks last ran
+	states, err := hubConn.ReportChecks(checks)
+	if err != nil {
+		WarningLog.Printf("Couldn't get checks from hub, running all checks now: %s", err)
+	}
+	_, queued := queue.Peek(queue.Len())
+	lastRuns := agent.LastRuns(states, queued)
+	go sender.Run(nil)
 
+	now := time.Now()
 	pq := make(PriorityQueue, len(checks))
 	for i, c := range checks {
-		pq[i] = &timedCheck{time: time.Now(), check: c}
+		pq[i] = &timedCheck{time: agent.FirstRun(c, lastRuns[agent.CheckKey(c)], now), check: c}
 	}
 	heap.Init(&pq)
 
-	if len(pq) < 1 {
-		ErrorLog.Fatalf("No checks to run")
-	}
+	// Reload checks.json on SIGHUP or when it changes
+	reload := make(chan struct{}, 1)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			select {
+			case reload <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	go watchFile(checksConfigFile(), checksWatchInterval, reload)
+
+	// Reloaded checks are reported one at a time, so that the hub gets them in order. Only the latest
+	// list waits to be reported.
+	reports := make(chan []chk.Check, 1)
+	go func() {
+		for checks := range reports {
+			_, err := hubConn.ReportChecks(checks)
+			if err != nil {
+				WarningLog.Printf("Couldn't report checks to hub: %s", err)
+			}
+		}
+	}()
+
+	// Results go through the main loop so that results of checks removed while they ran are dropped
+	results := make(chan messages.CheckResultMsg)
 
 	for {
 		next := pq[0]
 
 		// Sleep until next check is due
-		time.Sleep(next.time.Sub(time.Now()))
+		timer := time.NewTimer(time.Until(next.time))
+		select {
+		case <-timer.C:
+		case <-reload:
+			timer.Stop()
+			checks, err := loadChecks()
+			if err != nil {
+				ErrorLog.Printf("Error reading checks.json file, keeping the old checks: %s", err)
+				continue
+			}
+			reloadChecks(&pq, checks, time.Now())
+			// Replace a list that is not reported yet
+			select {
+			case <-reports:
+			default:
+			}
+			reports <- checks
+			continue
+		case msg := <-results:
+			timer.Stop()
+			if check, ok := pq.find(msg.Check); ok {
+				// Report the check as it is now, so the hub doesn't see an interval change back and forth
+				msg.Check = check
+				sender.Add(msg)
+			} else {
+				InfoLog.Printf("Dropping result of removed check %s", msg.Check.Title())
+			}
+			continue
+		}
 
 		DebugLog.Printf("running check %+v\n", next.check)
 
-		go func() {
-			res := next.check.Checker.Run(checkContext)
-			checkResult := messages.NewCheckResultMsg(next.check, res)
-			err = hubConn.SendCheckResult(cfg, checkResult)
-			if err != nil {
-				ErrorLog.Printf("Couldn't send result: %s\n", err)
-				// TODO: Try again
-			}
-		}()
+		go func(check chk.Check) {
+			res := check.Checker.Run(checkContext)
+			results <- messages.NewCheckResultMsg(check, res)
+		}(next.check)
 
 		next.time = time.Now().Add(time.Duration(next.check.Interval) * time.Second)
 		heap.Fix(&pq, 0)
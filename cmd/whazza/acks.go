@@ -0,0 +1,112 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/rymdhund/whazza/internal/logging"
+	"github.com/rymdhund/whazza/internal/persist"
+)
+
+type ackJson struct {
+	User      string    `json:"user"`
+	Comment   string    `json:"comment"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func optionalAckJson(ack *persist.AckModel) *ackJson {
+	if ack == nil {
+		return nil
+	}
+	return &ackJson{User: ack.User, Comment: ack.Comment, Timestamp: ack.Timestamp}
+}
+
+// ackCheck acknowledges the current problem of the check
+func ackCheck(db *persist.DB, id int, user, comment string) error {
+	checkModel, err := db.GetCheckById(id)
+	if err != nil {
+		return err
+	}
+	overview, err := db.GetCheckOverview(checkModel)
+	if err != nil {
+		return err
+	}
+	return db.AckCheck(checkModel, overview.Result.Status, persist.AckModel{
+		CheckID:   id,
+		User:      user,
+		Comment:   comment,
+		Timestamp: time.Now(),
+	})
+}
+
+func ackCommand(args []string) {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Printf("Invalid check id: %s\n", args[0])
+		os.Exit(1)
+	}
+	db, err := persist.Open(Config.Database())
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+	err = db.Init()
+	if err != nil {
+		panic(err)
+	}
+
+	err = ackCheck(db, id, cliUser(), strings.Join(args[1:], " "))
+	if errors.Is(err, sql.ErrNoRows) {
+		fmt.Printf("No check with id %d\n", id)
+		os.Exit(1)
+	} else if err != nil {
+		fmt.Printf("Couldn't ack check: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Acked check %d\n", id)
+}
+
+// apiAckHandler acks a check on POST /api/checks/{id}/ack with an optional json body {"comment": "..."}
+func apiAckHandler(w http.ResponseWriter, r *http.Request, user persist.UserModel, id int) {
+	if r.Method != "POST" {
+		http.Error(w, "405 Method Not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !user.HasRole(persist.RoleOperator) {
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+		return
+	}
+	var req struct {
+		Comment string `json:"comment"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && err != io.EOF {
+		http.Error(w, "400 Bad Request. Invalid data", http.StatusBadRequest)
+		return
+	}
+
+	withDb(w, func(db *persist.DB) {
+		err := ackCheck(db, id, user.Name, req.Comment)
+		if errors.Is(err, sql.ErrNoRows) {
+			notFoundHandler(w, r)
+			return
+		} else if errors.Is(err, persist.ErrNothingToAck) {
+			http.Error(w, fmt.Sprintf("409 Conflict. %s", err), http.StatusConflict)
+			return
+		} else if err != nil {
+			ErrorLog.Printf("Couldn't ack check %d: %s", id, err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		InfoLog.Printf("User %s acked check %d", user.Name, id)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
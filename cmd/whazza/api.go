@@ -0,0 +1,218 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/base"
+	. "github.com/rymdhund/whazza/internal/logging"
+	"github.com/rymdhund/whazza/internal/persist"
+)
+
+// Json representations of the hub state served by the api
+
+type resultJson struct {
+	ID        int       `json:"id,omitempty"`
+	Status    string    `json:"status"`
+	Msg       string    `json:"msg"`
+	Timestamp time.Time `json:"timestamp"`
+	// Set for rolled up results
+	Last  *time.Time `json:"last_timestamp,omitempty"`
+	Count int        `json:"count,omitempty"`
+}
+
+type checkJson struct {
+	ID           int             `json:"id"`
+	Agent        string          `json:"agent"`
+	Namespace    string          `json:"namespace"`
+	Type         string          `json:"type"`
+	Title        string          `json:"title"`
+	Interval     int             `json:"interval"`
+	Checker      json.RawMessage `json:"checker"`
+	Status       string          `json:"status"`
+	Msg          string          `json:"msg"`
+	LastReceived *resultJson     `json:"last_received"`
+	LastGood     *resultJson     `json:"last_good"`
+	LastFail     *resultJson     `json:"last_fail"`
+	Silenced     bool            `json:"silenced"`
+	Ack          *ackJson        `json:"ack"`
+	Retired      *time.Time      `json:"retired,omitempty"`
+}
+
+type agentJson struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// optionalResultJson returns nil for empty results, eg when a check has never been good
+func optionalResultJson(res base.Result) *resultJson {
+	if res.Timestamp.IsZero() {
+		return nil
+	}
+	return &resultJson{Status: res.Status, Msg: res.Msg, Timestamp: res.Timestamp}
+}
+
+func overviewJson(o persist.CheckOverview) checkJson {
+	check := o.CheckModel.Check
+	var retired *time.Time
+	if !o.CheckModel.Retired.IsZero() {
+		retired = &o.CheckModel.Retired
+	}
+	return checkJson{
+		ID:           o.CheckModel.ID,
+		Agent:        o.CheckModel.Agent.Name,
+		Namespace:    check.Namespace,
+		Type:         check.Type,
+		Title:        check.Title(),
+		Interval:     check.Interval,
+		Checker:      check.Checker.AsJson(),
+		Status:       o.Result.Status,
+		Msg:          o.Result.Msg,
+		LastReceived: optionalResultJson(o.LastReceived),
+		LastGood:     optionalResultJson(o.LastGood),
+		LastFail:     optionalResultJson(o.LastFail),
+		Silenced:     o.Silenced,
+		Ack:          optionalAckJson(o.Ack),
+		Retired:      retired,
+	}
+}
+
+func writeJson(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(data)
+	if err != nil {
+		ErrorLog.Printf("Couldn't write json: %s", err)
+	}
+}
+
+// parseSince parses a time given as unix seconds or in RFC3339 format
+func parseSince(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func withDb(w http.ResponseWriter, f func(db *persist.DB)) {
+	db, err := persist.Open(Config.Database())
+	if err != nil {
+		ErrorLog.Printf("Couldn't open db: %s", err)
+		http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+	f(db)
+}
+
+func apiChecksHandler(w http.ResponseWriter, r *http.Request, user persist.UserModel) {
+	if r.Method != "GET" {
+		http.Error(w, "405 Method Not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	withDb(w, func(db *persist.DB) {
+		overviews, err := db.GetCheckOverviews(r.URL.Query().Get("all") != "")
+		if err != nil {
+			ErrorLog.Printf("Couldn't get check overviews: %s", err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		checks := make([]checkJson, 0, len(overviews))
+		for _, o := range overviews {
+			checks = append(checks, overviewJson(o))
+		}
+		writeJson(w, checks)
+	})
+}
+
+// apiCheckHandler serves /api/checks/{id}, /api/checks/{id}/results and /api/checks/{id}/ack
+func apiCheckHandler(w http.ResponseWriter, r *http.Request, user persist.UserModel) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/checks/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || len(parts) > 2 || (len(parts) == 2 && parts[1] != "results" && parts[1] != "ack") {
+		notFoundHandler(w, r)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "ack" {
+		apiAckHandler(w, r, user, id)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, "405 Method Not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	withDb(w, func(db *persist.DB) {
+		checkModel, err := db.GetCheckById(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			notFoundHandler(w, r)
+			return
+		} else if err != nil {
+			ErrorLog.Printf("Couldn't get check %d: %s", id, err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		if len(parts) == 1 {
+			overview, err := db.GetCheckOverview(checkModel)
+			if err != nil {
+				ErrorLog.Printf("Couldn't get check overview %d: %s", id, err)
+				http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			writeJson(w, overviewJson(overview))
+			return
+		}
+
+		since, err := parseSince(r.URL.Query().Get("since"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("400 Bad Request. Invalid since: %s", err), http.StatusBadRequest)
+			return
+		}
+		results, err := db.GetResults(checkModel.ID, since)
+		if err != nil {
+			ErrorLog.Printf("Couldn't get results for check %d: %s", id, err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		resultsJson := make([]resultJson, 0, len(results))
+		for _, res := range results {
+			rj := resultJson{ID: res.ID, Status: res.Status, Msg: res.Msg, Timestamp: res.Timestamp}
+			if res.Count > 1 {
+				last := res.Last
+				rj.Last = &last
+				rj.Count = res.Count
+			}
+			resultsJson = append(resultsJson, rj)
+		}
+		writeJson(w, resultsJson)
+	})
+}
+
+func apiAgentsHandler(w http.ResponseWriter, r *http.Request, user persist.UserModel) {
+	if r.Method != "GET" {
+		http.Error(w, "405 Method Not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	withDb(w, func(db *persist.DB) {
+		agents, err := db.GetAgents()
+		if err != nil {
+			ErrorLog.Printf("Couldn't get agents: %s", err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		agentsJson := make([]agentJson, 0, len(agents))
+		for _, a := range agents {
+			agentsJson = append(agentsJson, agentJson{ID: a.ID, Name: a.Name})
+		}
+		writeJson(w, agentsJson)
+	})
+}
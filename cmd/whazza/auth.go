@@ -0,0 +1,170 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	. "github.com/rymdhund/whazza/internal/logging"
+	"github.com/rymdhund/whazza/internal/persist"
+	"github.com/rymdhund/whazza/internal/sectoken"
+)
+
+// UserHandlerFunc is a handler for the human facing interfaces, authenticated as a hub user
+type UserHandlerFunc func(http.ResponseWriter, *http.Request, persist.UserModel)
+
+const sessionCookie = "whazza_session"
+
+const sessionDuration = 7 * 24 * time.Hour
+
+// authUser authenticates the request by an api token ("Authorization: Bearer <token>") or a session cookie
+func authUser(r *http.Request) (persist.UserModel, bool, error) {
+	db, err := persist.Open(Config.Database())
+	if err != nil {
+		return persist.UserModel{}, false, err
+	}
+	defer db.Close()
+
+	if bearer := r.Header.Get("Authorization"); strings.HasPrefix(bearer, "Bearer ") {
+		token := sectoken.SecToken(strings.TrimSpace(strings.TrimPrefix(bearer, "Bearer ")))
+		return db.AuthenticateApiToken(token)
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil && cookie.Value != "" {
+		return db.AuthenticateSession(sectoken.SecToken(cookie.Value))
+	}
+	return persist.UserModel{}, false, nil
+}
+
+// apiAuth requires a user with at least the given role. Unauthenticated requests get a 401.
+func apiAuth(role string, handler UserHandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, found, err := authUser(r)
+		if err != nil {
+			ErrorLog.Printf("Error authenticating user: %s", err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !user.HasRole(role) {
+			http.Error(w, "403 Forbidden", http.StatusForbidden)
+			return
+		}
+		handler(w, r, user)
+	}
+}
+
+// webAuth requires a user with at least the given role. Unauthenticated requests are sent to the login page.
+func webAuth(role string, handler UserHandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, found, err := authUser(r)
+		if err != nil {
+			ErrorLog.Printf("Error authenticating user: %s", err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+		if !user.HasRole(role) {
+			http.Error(w, "403 Forbidden", http.StatusForbidden)
+			return
+		}
+		handler(w, r, user)
+	}
+}
+
+// safeNext only allows redirects to local paths after login
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	next := safeNext(r.FormValue("next"))
+	data := struct {
+		pageData
+		Next  string
+		Error string
+	}{
+		pageData: pageData{Title: "Login", Now: time.Now()},
+		Next:     next,
+	}
+
+	switch r.Method {
+	case "GET":
+		renderTemplate(w, "login.html", data)
+	case "POST":
+		db, err := persist.Open(Config.Database())
+		if err != nil {
+			ErrorLog.Printf("Couldn't open db: %s", err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		defer db.Close()
+
+		user, found, err := db.AuthenticateUser(r.PostFormValue("username"), r.PostFormValue("password"))
+		if err != nil {
+			ErrorLog.Printf("Error authenticating user: %s", err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			InfoLog.Printf("Incorrect login for user %s", r.PostFormValue("username"))
+			data.Error = "Incorrect username or password"
+			w.WriteHeader(http.StatusUnauthorized)
+			renderTemplate(w, "login.html", data)
+			return
+		}
+		token, err := db.CreateSession(user, time.Now().Add(sessionDuration))
+		if err != nil {
+			ErrorLog.Printf("Couldn't create session: %s", err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookie,
+			Value:    token.String(),
+			Path:     "/",
+			Expires:  time.Now().Add(sessionDuration),
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, next, http.StatusSeeOther)
+	default:
+		http.Error(w, "405 Method Not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "405 Method Not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		db, err := persist.Open(Config.Database())
+		if err == nil {
+			err = db.DeleteSession(sectoken.SecToken(cookie.Value))
+			db.Close()
+		}
+		if err != nil {
+			ErrorLog.Printf("Couldn't delete session: %s", err)
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+	})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/persist"
+)
+
+// checkCommand retires or deletes a check by id
+func checkCommand(args []string) {
+	if len(args) != 2 || (args[0] != "retire" && args[0] != "delete") {
+		showUsage()
+		os.Exit(1)
+	}
+	id, err := strconv.Atoi(args[1])
+	if err != nil {
+		fmt.Printf("Invalid check id: %s\n", args[1])
+		os.Exit(1)
+	}
+	db, err := persist.Open(Config.Database())
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+	err = db.Init()
+	if err != nil {
+		panic(err)
+	}
+
+	check, err := db.GetCheckById(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		fmt.Printf("No check with id %d\n", id)
+		os.Exit(1)
+	} else if err != nil {
+		panic(err)
+	}
+
+	now := time.Now()
+	switch args[0] {
+	case "retire":
+		if !check.Retired.IsZero() {
+			fmt.Printf("Check %d is already retired\n", id)
+			return
+		}
+		err = db.RetireCheck(check, cliUser(), now)
+		if err != nil {
+			fmt.Printf("Couldn't retire check: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Retired check %d %s\n", id, check.Check.Title())
+	case "delete":
+		err = db.DeleteCheck(check, cliUser(), now)
+		if err != nil {
+			fmt.Printf("Couldn't delete check: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Deleted check %d %s\n", id, check.Check.Title())
+	}
+}
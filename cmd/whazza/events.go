@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	. "github.com/rymdhund/whazza/internal/logging"
+	"github.com/rymdhund/whazza/internal/persist"
+)
+
+type eventJson struct {
+	ID        int       `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Kind      string    `json:"kind"`
+	CheckID   int       `json:"check_id,omitempty"`
+	Agent     string    `json:"agent,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	User      string    `json:"user,omitempty"`
+	Message   string    `json:"message"`
+}
+
+// eventFilter parses an event filter from query parameters. Since and until are like in reports.
+func eventFilter(query url.Values, now time.Time) (persist.EventFilter, error) {
+	f := persist.EventFilter{
+		Kind:      query.Get("kind"),
+		Agent:     query.Get("agent"),
+		Namespace: query.Get("namespace"),
+	}
+	var err error
+	if s := query.Get("check"); s != "" {
+		f.CheckID, err = strconv.Atoi(s)
+		if err != nil {
+			return f, fmt.Errorf("Invalid check id: %s", s)
+		}
+	}
+	if s := query.Get("since"); s != "" {
+		f.Since, err = parseReportTime(s, now)
+		if err != nil {
+			return f, fmt.Errorf("Invalid since: %w", err)
+		}
+	}
+	if s := query.Get("until"); s != "" {
+		f.Until, err = parseReportTime(s, now)
+		if err != nil {
+			return f, fmt.Errorf("Invalid until: %w", err)
+		}
+	}
+	if s := query.Get("limit"); s != "" {
+		f.Limit, err = strconv.Atoi(s)
+		if err != nil {
+			return f, fmt.Errorf("Invalid limit: %s", s)
+		}
+	}
+	return f, nil
+}
+
+func eventsCommand(args []string) {
+	flags := flag.NewFlagSet("events", flag.ExitOnError)
+	query := url.Values{}
+	for _, opt := range []struct{ name, usage string }{
+		{"kind", "Only events of this kind, eg status, check_added, check_changed, ack or silence_added"},
+		{"check", "Only events of the check with this id"},
+		{"agent", "Only events of this agent"},
+		{"namespace", "Only events in namespaces matching this glob pattern"},
+		{"since", "Only events after this time or duration before now, eg 7d"},
+		{"until", "Only events before this time or duration before now"},
+		{"limit", "Max number of events, default 100"},
+	} {
+		name := opt.name
+		flags.Func(name, opt.usage, func(s string) error {
+			query.Set(name, s)
+			return nil
+		})
+	}
+	flags.Parse(args)
+
+	f, err := eventFilter(query, time.Now())
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	db, err := persist.Open(Config.Database())
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+	err = db.Init()
+	if err != nil {
+		panic(err)
+	}
+
+	events, err := db.GetEvents(f)
+	if err != nil {
+		panic(err)
+	}
+	// Oldest first like a log
+	for i := len(events) - 1; i >= 0; i-- {
+		fmt.Println(events[i].Show())
+	}
+}
+
+// apiEventsHandler serves GET /api/events with the same filters as the events command as query parameters
+func apiEventsHandler(w http.ResponseWriter, r *http.Request, user persist.UserModel) {
+	if r.Method != "GET" {
+		http.Error(w, "405 Method Not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	f, err := eventFilter(r.URL.Query(), time.Now())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("400 Bad Request. %s", err), http.StatusBadRequest)
+		return
+	}
+	withDb(w, func(db *persist.DB) {
+		events, err := db.GetEvents(f)
+		if err != nil {
+			ErrorLog.Printf("Couldn't get events: %s", err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		eventsJson := make([]eventJson, 0, len(events))
+		for _, e := range events {
+			eventsJson = append(eventsJson, eventJson{
+				ID:        e.ID,
+				Timestamp: e.Timestamp,
+				Kind:      e.Kind,
+				CheckID:   e.CheckID,
+				Agent:     e.Agent,
+				Namespace: e.Namespace,
+				Status:    e.Status,
+				User:      e.User,
+				Message:   e.Message,
+			})
+		}
+		writeJson(w, eventsJson)
+	})
+}
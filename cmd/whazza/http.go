@@ -42,9 +42,13 @@ func startServer() {
 	}
 	db.Close()
 
-	mon := monitor.New(Config, time.Now())
+	mon, err := monitor.New(Config, time.Now())
+	if err != nil {
+		panic(err)
+	}
 	dbWorker := persist.NewDbWorker()
 	dbWorker.Run(Config.Database())
+	go runRetention(Config.Retention, dbWorker)
 
 	go func() {
 		for {
@@ -52,13 +56,33 @@ func startServer() {
 			if err != nil {
 				ErrorLog.Printf("Error in CheckForExpired: %s", err)
 			}
+			err = mon.SendFollowUps()
+			if err != nil {
+				ErrorLog.Printf("Error in SendFollowUps: %s", err)
+			}
+			err = mon.SendDigests()
+			if err != nil {
+				ErrorLog.Printf("Error in SendDigests: %s", err)
+			}
 			time.Sleep(10 * time.Second)
 		}
 	}()
 
-	http.HandleFunc("/", notFoundHandler)
+	http.HandleFunc("/", rootHandler(webAuth(persist.RoleViewer, dashboardHandler)))
+	http.HandleFunc("/checks/", webAuth(persist.RoleViewer, checkPageHandler))
+	http.HandleFunc("/login", loginHandler)
+	http.HandleFunc("/logout", logoutHandler)
 	http.HandleFunc("/agent/ping", basicAuth(pingHandler))
+	http.HandleFunc("/agent/checks", basicAuth(agentChecksHandler))
 	http.HandleFunc("/agent/result", basicAuth(mkResultHandler(mon, dbWorker)))
+	http.HandleFunc("/agent/results", basicAuth(mkResultsHandler(mon, dbWorker)))
+	http.HandleFunc("/api/checks", apiAuth(persist.RoleViewer, apiChecksHandler))
+	http.HandleFunc("/api/checks/", apiAuth(persist.RoleViewer, apiCheckHandler))
+	http.HandleFunc("/api/agents", apiAuth(persist.RoleViewer, apiAgentsHandler))
+	http.HandleFunc("/api/events", apiAuth(persist.RoleViewer, apiEventsHandler))
+	http.HandleFunc("/api/report", apiAuth(persist.RoleViewer, apiReportHandler))
+	http.HandleFunc("/api/silences", apiAuth(persist.RoleViewer, apiSilencesHandler))
+	http.HandleFunc("/api/silences/", apiAuth(persist.RoleViewer, apiSilenceHandler))
 
 	addr := fmt.Sprintf(":%d", Config.Port)
 
@@ -72,6 +96,18 @@ func notFoundHandler(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "404 Not found", http.StatusNotFound)
 }
 
+// rootHandler serves "/" with the handler, and everything else that no other handler matches as not
+// found without asking for a login first
+func rootHandler(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			notFoundHandler(w, r)
+			return
+		}
+		handler(w, r)
+	}
+}
+
 func pingHandler(w http.ResponseWriter, r *http.Request, agent persist.AgentModel) {
 	switch r.Method {
 	case "GET":
@@ -83,6 +119,54 @@ func pingHandler(w http.ResponseWriter, r *http.Request, agent persist.AgentMode
 	}
 }
 
+// agentChecksHandler tells an agent which of its checks the hub knows and when they last ran. An agent
+// POSTs the checks it currently runs, and its other checks are retired.
+func agentChecksHandler(w http.ResponseWriter, r *http.Request, agent persist.AgentModel) {
+	if r.Method != "GET" && r.Method != "POST" {
+		http.Error(w, "405 Method Not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var current []chk.Check
+	if r.Method == "POST" {
+		err := json.NewDecoder(r.Body).Decode(&current)
+		if err != nil {
+			ErrorLog.Printf("Couldn't decode checks of agent %s: %s", agent.Name, err)
+			http.Error(w, "400 Bad Request. Invalid data", http.StatusBadRequest)
+			return
+		}
+	}
+	withDb(w, func(db *persist.DB) {
+		if r.Method == "POST" {
+			retired, err := db.RetireMissingChecks(agent, current, time.Now())
+			if err != nil {
+				ErrorLog.Printf("Couldn't retire checks of agent %s: %s", agent.Name, err)
+				http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			for _, c := range retired {
+				InfoLog.Printf("Retired check %d %s, agent %s no longer runs it", c.ID, c.Check.Title(), agent.Name)
+			}
+		}
+		checks, err := db.GetAgentChecks(agent)
+		if err != nil {
+			ErrorLog.Printf("Couldn't get checks of agent %s: %s", agent.Name, err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		lastRuns, err := db.LastResultTimes(agent)
+		if err != nil {
+			ErrorLog.Printf("Couldn't get last results of agent %s: %s", agent.Name, err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		states := make([]messages.CheckStateMsg, 0, len(checks))
+		for _, c := range checks {
+			states = append(states, messages.CheckStateMsg{Check: c.Check, LastRun: lastRuns[c.ID]})
+		}
+		writeJson(w, states)
+	})
+}
+
 func mkResultHandler(mon *monitor.Monitor, dbWorker *persist.DbWorker) AuthHandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, agent persist.AgentModel) {
 		resultHandler(w, r, agent, mon, dbWorker)
@@ -121,6 +205,130 @@ func resultHandler(w http.ResponseWriter, r *http.Request, agent persist.AgentMo
 	}
 }
 
+func mkResultsHandler(mon *monitor.Monitor, dbWorker *persist.DbWorker) AuthHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, agent persist.AgentModel) {
+		resultsHandler(w, r, agent, mon, dbWorker)
+	}
+}
+
+// resultsHandler accepts a json array of check results and responds with the status of each of them.
+// Results are saved in one transaction, but results that are invalid or can't be saved are rejected
+// one by one.
+func resultsHandler(w http.ResponseWriter, r *http.Request, agent persist.AgentModel, mon *monitor.Monitor, dbWorker *persist.DbWorker) {
+	if r.Method != "POST" {
+		ErrorLog.Print("Got results with incorrect method")
+		http.Error(w, "405 Method Not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var items []json.RawMessage
+	err := json.NewDecoder(r.Body).Decode(&items)
+	if err != nil {
+		ErrorLog.Printf("Couldn't decode checkresults: %s", err)
+		http.Error(w, "400 Bad Request. Invalid data", http.StatusBadRequest)
+		return
+	}
+	if len(items) > messages.MaxBatchSize {
+		http.Error(w, fmt.Sprintf("400 Bad Request. At most %d results per batch", messages.MaxBatchSize), http.StatusBadRequest)
+		return
+	}
+
+	statuses := make([]messages.ResultStatusMsg, len(items))
+	checkResults := []messages.CheckResultMsg{}
+	// Index in items of each of the valid results
+	indexes := []int{}
+	for i, item := range items {
+		var checkResult messages.CheckResultMsg
+		err := json.Unmarshal(item, &checkResult)
+		if err != nil {
+			statuses[i].Error = fmt.Sprintf("Invalid data: %s", err)
+			continue
+		}
+		ok, e := checkResult.Validate()
+		if !ok {
+			statuses[i].Error = e
+			continue
+		}
+		checkResults = append(checkResults, checkResult)
+		indexes = append(indexes, i)
+	}
+	DebugLog.Printf("Got %d CheckResults, %d valid", len(items), len(checkResults))
+
+	errs, err := saveResults(agent, checkResults, mon, dbWorker)
+	if err != nil {
+		ErrorLog.Printf("Couldn't save checkresults: %s", err)
+		http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	for j, i := range indexes {
+		if errs[j] != nil {
+			ErrorLog.Printf("Couldn't save checkresult: %s", errs[j])
+			statuses[i].Error = errs[j].Error()
+		} else {
+			statuses[i].OK = true
+		}
+	}
+	writeJson(w, statuses)
+}
+
+// saveResults saves the results in one transaction and then lets the monitor handle them in order. Each
+// result is saved in a savepoint, so that a result that can't be saved is rolled back by itself and its
+// error is returned at its index. The error is set if the transaction fails.
+func saveResults(agent persist.AgentModel, checkResults []messages.CheckResultMsg, mon *monitor.Monitor, dbWorker *persist.DbWorker) ([]error, error) {
+	errs := make([]error, len(checkResults))
+	if len(checkResults) == 0 {
+		return errs, nil
+	}
+	type saved struct {
+		check persist.CheckModel
+		res   persist.ResultModel
+	}
+	var saveds []saved
+	future := dbWorker.AddWork(func(db *persist.DB) error {
+		saveds = nil
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		for i, cr := range checkResults {
+			var s saved
+			errs[i], err = tx.Savepoint(func() error {
+				checkModel, err := tx.RegisterCheck(agent, cr.Check)
+				if err != nil {
+					return fmt.Errorf("Couldn't register check: %w", err)
+				}
+				res, err := tx.AddResult(agent, checkModel, cr.Result)
+				if err != nil {
+					return fmt.Errorf("Couldn't add result: %w", err)
+				}
+				s = saved{checkModel, res}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if errs[i] == nil {
+				saveds = append(saveds, s)
+			}
+		}
+		return tx.Commit()
+	})
+	err := <-future
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for _, s := range saveds {
+			err := mon.HandleResult(s.check, s.res)
+			if err != nil {
+				ErrorLog.Printf("Monitor handle result error: %s", err)
+			}
+		}
+	}()
+	return errs, nil
+}
+
 func saveResult(agent persist.AgentModel, check chk.Check, result base.Result, mon *monitor.Monitor, dbWorker *persist.DbWorker) error {
 	future := dbWorker.AddWork(func(db *persist.DB) error {
 		// register check if not exists
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/hubutil"
+	. "github.com/rymdhund/whazza/internal/logging"
+	"github.com/rymdhund/whazza/internal/messages"
+	"github.com/rymdhund/whazza/internal/monitor"
+	"github.com/rymdhund/whazza/internal/persist"
+)
+
+func TestMain(m *testing.M) {
+	DebugLog = log.New(ioutil.Discard, "", 0)
+	InfoLog = log.New(ioutil.Discard, "", 0)
+	WarningLog = log.New(ioutil.Discard, "", 0)
+	ErrorLog = log.New(ioutil.Discard, "", 0)
+	os.Exit(m.Run())
+}
+
+func TestResultsHandler(t *testing.T) {
+	// The monitor handles results in the background after the response, so the directory is removed
+	// without failing the test
+	dir, err := os.MkdirTemp("", "whazza-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := hubutil.HubConfig{DataDir: dir, DisableFlapDetection: true}
+
+	db, err := persist.Open(cfg.Database())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveAgent("agent", ""); err != nil {
+		t.Fatal(err)
+	}
+	agent := persist.AgentModel{ID: 1, Name: "agent"}
+	// Make saving one of the results fail in the database
+	_, err = db.Exec(`CREATE TRIGGER fail_result BEFORE INSERT ON results WHEN NEW.status_msg = 'boom'
+		BEGIN SELECT RAISE(ABORT, 'boom'); END`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mon, err := monitor.New(cfg, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbWorker := persist.NewDbWorker()
+	dbWorker.Run(cfg.Database())
+
+	ts := time.Unix(1600000000, 0).UTC().Format(time.RFC3339)
+	item := func(host, status, msg string) string {
+		return `{"Check": {"type": "http-up", "namespace": "web", "interval": 60, "host": "` + host + `"},
+			"Result": {"Status": "` + status + `", "Msg": "` + msg + `", "Timestamp": "` + ts + `"}}`
+	}
+	body := "[" + strings.Join([]string{
+		item("a.example.com", "good", ""),
+		`"not a result"`,
+		item("b.example.com", "bogus", ""),
+		item("c.example.com", "fail", "boom"),
+		item("d.example.com", "fail", "down"),
+	}, ",") + "]"
+
+	w := httptest.NewRecorder()
+	resultsHandler(w, httptest.NewRequest("POST", "/agent/results", strings.NewReader(body)), agent, mon, dbWorker)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var statuses []messages.ResultStatusMsg
+	if err := json.NewDecoder(w.Body).Decode(&statuses); err != nil {
+		t.Fatal(err)
+	}
+	expected := []bool{true, false, false, false, true}
+	if len(statuses) != len(expected) {
+		t.Fatalf("Expected %d statuses, got %+v", len(expected), statuses)
+	}
+	for i, ok := range expected {
+		if statuses[i].OK != ok || (statuses[i].Error == "") != ok {
+			t.Errorf("Unexpected status %d: %+v", i, statuses[i])
+		}
+	}
+
+	// The result that failed is rolled back with its check, the others are saved
+	checks, err := db.GetChecks(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	titles := []string{}
+	for _, c := range checks {
+		titles = append(titles, c.Check.Title())
+		results, err := db.GetResults(c.ID, time.Time{})
+		if err != nil || len(results) != 1 {
+			t.Errorf("Expected one result for %s, got %+v %v", c.Check.Title(), results, err)
+		}
+	}
+	if strings.Join(titles, " ") != "http:a.example.com http:d.example.com" {
+		t.Fatalf("Unexpected checks %v", titles)
+	}
+}
+
+func TestRootHandler(t *testing.T) {
+	oldConfig := Config
+	Config = hubutil.HubConfig{DataDir: t.TempDir()}
+	defer func() { Config = oldConfig }()
+	handler := rootHandler(webAuth(persist.RoleViewer, dashboardHandler))
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("GET", "/nope", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 for unknown path, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusSeeOther || !strings.HasPrefix(rec.Header().Get("Location"), "/login") {
+		t.Fatalf("Expected redirect to login, got %d", rec.Code)
+	}
+}
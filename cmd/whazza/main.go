@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/user"
 	"path"
 
 	"github.com/rymdhund/whazza/internal/hubutil"
@@ -35,9 +36,27 @@ func main() {
 	} else if args[1] == "fingerprint" && len(args) == 2 {
 		initConf()
 		showFingerprint()
-	} else if args[1] == "show" && len(args) == 2 {
+	} else if args[1] == "show" && (len(args) == 2 || (len(args) == 3 && args[2] == "--all")) {
 		initConf()
-		show()
+		show(len(args) == 3)
+	} else if args[1] == "check" && len(args) >= 3 {
+		initConf()
+		checkCommand(args[2:])
+	} else if args[1] == "user" && len(args) >= 3 {
+		initConf()
+		userCommand(args[2:])
+	} else if args[1] == "ack" && len(args) >= 3 {
+		initConf()
+		ackCommand(args[2:])
+	} else if args[1] == "events" {
+		initConf()
+		eventsCommand(args[2:])
+	} else if args[1] == "report" {
+		initConf()
+		reportCommand(args[2:])
+	} else if args[1] == "silence" && len(args) >= 3 {
+		initConf()
+		silenceCommand(args[2:])
 	} else if args[1] == "register-external" && len(args) == 3 {
 		initConf()
 
@@ -67,17 +86,32 @@ Where command is one of the following:
   fingerprint                       Show the certificate fingerprint
   register <agent> <token hash>     Register the agent with a hashed token
   register-external <name>          Register a new external agent and generate a token
-  show                              Show status of checks
+  show [--all]                      Show status of checks, including retired checks with --all
+  check retire <id>                 Retire a check that is no longer run, it won't expire or be shown
+  check delete <id>                 Delete a check and its results
+  ack <check id> [<comment>]        Acknowledge the problem of a check until it recovers
+  events [<filters>]                Show the event log, see events -h
+  report [--since 30d] [--until t]  Show uptime, incidents, MTTR and MTBF per namespace and check
+  user list                         List hub users
+  user add <name> <role>            Add a user with role viewer or operator
+  user remove <name>                Remove a user
+  user passwd <name>                Change the password of a user
+  user token add <name> [<token>]   Generate an api token for a user
+  user token list <name>            List the api tokens of a user
+  user token revoke <name> <id>     Revoke an api token of a user
+  silence add [<options>]           Silence notifications, see silence add -h
+  silence list [--all]              List silences, including expired with --all
+  silence expire <id>               End a silence now
 `, os.Args[0])
 }
 
-func show() {
+func show(all bool) {
 	db, err := persist.Open(Config.Database())
 	if err != nil {
 		panic(err)
 	}
 	defer db.Close()
-	overviews, err := db.GetCheckOverviews()
+	overviews, err := db.GetCheckOverviews(all)
 	if err != nil {
 		panic(err)
 	}
@@ -111,6 +145,17 @@ func registerAgent(name, tokenHash string) {
 	}
 }
 
+// cliUser is the name of the user running a command, used to record who did something
+func cliUser() string {
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "unknown"
+}
+
 func initConf() {
 	cfgFile := os.Getenv("WHAZZA_CONFIG_FILE")
 	if cfgFile == "" {
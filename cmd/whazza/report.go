@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	. "github.com/rymdhund/whazza/internal/logging"
+	"github.com/rymdhund/whazza/internal/persist"
+	"github.com/rymdhund/whazza/internal/report"
+	"github.com/rymdhund/whazza/internal/utils"
+)
+
+type statsJson struct {
+	Availability   float64 `json:"availability"`
+	UpSeconds      int64   `json:"up_seconds"`
+	DownSeconds    int64   `json:"down_seconds"`
+	UnknownSeconds int64   `json:"unknown_seconds"`
+	Incidents      int     `json:"incidents"`
+	MTTRSeconds    int64   `json:"mttr_seconds"`
+	MTBFSeconds    int64   `json:"mtbf_seconds"`
+}
+
+type checkReportJson struct {
+	ID    int    `json:"id"`
+	Agent string `json:"agent"`
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	statsJson
+}
+
+type namespaceReportJson struct {
+	Namespace string `json:"namespace"`
+	statsJson
+	Checks []checkReportJson `json:"checks"`
+}
+
+type reportJson struct {
+	From       time.Time             `json:"from"`
+	To         time.Time             `json:"to"`
+	Namespaces []namespaceReportJson `json:"namespaces"`
+}
+
+func mkStatsJson(s report.Stats) statsJson {
+	return statsJson{
+		Availability:   s.Percent(),
+		UpSeconds:      int64(s.Up.Seconds()),
+		DownSeconds:    int64(s.Down.Seconds()),
+		UnknownSeconds: int64(s.Unknown.Seconds()),
+		Incidents:      s.Incidents,
+		MTTRSeconds:    int64(s.MTTR().Seconds()),
+		MTBFSeconds:    int64(s.MTBF().Seconds()),
+	}
+}
+
+func mkReportJson(r report.Report) reportJson {
+	namespaces := make([]namespaceReportJson, 0, len(r.Namespaces))
+	for _, ns := range r.Namespaces {
+		checks := make([]checkReportJson, 0, len(ns.Checks))
+		for _, c := range ns.Checks {
+			checks = append(checks, checkReportJson{
+				ID:        c.Check.ID,
+				Agent:     c.Check.Agent.Name,
+				Type:      c.Check.Check.Type,
+				Title:     c.Check.Check.Title(),
+				statsJson: mkStatsJson(c.Stats),
+			})
+		}
+		namespaces = append(namespaces, namespaceReportJson{ns.Namespace, mkStatsJson(ns.Stats), checks})
+	}
+	return reportJson{r.From, r.To, namespaces}
+}
+
+// parseReportTime parses a time as a duration before now, eg "30d", as unix seconds or as a time accepted by parseTime
+func parseReportTime(s string, now time.Time) (time.Time, error) {
+	if d, err := utils.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return parseTime(s)
+}
+
+// reportPeriod parses the period of a report. Since defaults to 30 days ago and until to now.
+func reportPeriod(since, until string, now time.Time) (time.Time, time.Time, error) {
+	from, to := now.Add(-30*24*time.Hour), now
+	var err error
+	if since != "" {
+		from, err = parseReportTime(since, now)
+		if err != nil {
+			return from, to, fmt.Errorf("Invalid since: %w", err)
+		}
+	}
+	if until != "" {
+		to, err = parseReportTime(until, now)
+		if err != nil {
+			return from, to, fmt.Errorf("Invalid until: %w", err)
+		}
+	}
+	if !to.After(from) {
+		return from, to, errors.New("The report must end after it starts")
+	}
+	return from, to, nil
+}
+
+func reportCommand(args []string) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	since := flags.String("since", "30d", "Start of the report, a duration before now like 30d or a time")
+	until := flags.String("until", "", "End of the report, a duration before now or a time, default now")
+	flags.Parse(args)
+
+	from, to, err := reportPeriod(*since, *until, time.Now())
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	db, err := persist.Open(Config.Database())
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+	err = db.Init()
+	if err != nil {
+		panic(err)
+	}
+
+	r, err := report.BuildReport(db, from, to, report.Options{ExpiredAsDown: Config.ExpiredAsDown()})
+	if err != nil {
+		fmt.Printf("Couldn't build report: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Print(r.Text())
+}
+
+// apiReportHandler serves the uptime report on GET /api/report?since=30d&until=
+func apiReportHandler(w http.ResponseWriter, r *http.Request, user persist.UserModel) {
+	if r.Method != "GET" {
+		http.Error(w, "405 Method Not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	from, to, err := reportPeriod(query.Get("since"), query.Get("until"), time.Now())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("400 Bad Request. %s", err), http.StatusBadRequest)
+		return
+	}
+	withDb(w, func(db *persist.DB) {
+		rep, err := report.BuildReport(db, from, to, report.Options{ExpiredAsDown: Config.ExpiredAsDown()})
+		if err != nil {
+			ErrorLog.Printf("Couldn't build report: %s", err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		writeJson(w, mkReportJson(rep))
+	})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"time"
+
+	"github.com/rymdhund/whazza/internal/hubutil"
+	. "github.com/rymdhund/whazza/internal/logging"
+	"github.com/rymdhund/whazza/internal/persist"
+)
+
+// How often old results are compacted and pruned
+const retentionInterval = time.Hour
+
+// Max number of results handled in one go, so that incoming results don't wait too long for the db worker
+const retentionBatch = 5000
+
+func days(n int) time.Duration {
+	return time.Duration(n) * 24 * time.Hour
+}
+
+// runRetention periodically applies the retention policy
+func runRetention(retention hubutil.RetentionConfig, dbWorker *persist.DbWorker) {
+	if retention.RawDays == 0 && retention.KeepDays == 0 {
+		return
+	}
+	for {
+		err := applyRetention(retention, dbWorker, time.Now())
+		if err != nil {
+			ErrorLog.Printf("Error in applyRetention: %s", err)
+		}
+		time.Sleep(retentionInterval)
+	}
+}
+
+// inBatches runs work on the db worker until it reports that it handled nothing
+func inBatches(dbWorker *persist.DbWorker, work func(db *persist.DB) (int, error)) (int, error) {
+	total := 0
+	for {
+		var n int
+		err := <-dbWorker.AddWork(func(db *persist.DB) error {
+			var err error
+			n, err = work(db)
+			return err
+		})
+		if err != nil || n == 0 {
+			return total, err
+		}
+		total += n
+	}
+}
+
+func applyRetention(retention hubutil.RetentionConfig, dbWorker *persist.DbWorker, now time.Time) error {
+	var checks []persist.CheckModel
+	err := <-dbWorker.AddWork(func(db *persist.DB) error {
+		var err error
+		checks, err = db.GetChecks(true)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	pruned, compacted := 0, 0
+	for _, check := range checks {
+		if retention.KeepDays > 0 {
+			before := now.Add(-days(retention.KeepDays))
+			n, err := inBatches(dbWorker, func(db *persist.DB) (int, error) {
+				return db.PruneResults(check.ID, before, retentionBatch)
+			})
+			if err != nil {
+				return err
+			}
+			pruned += n
+		}
+		if retention.RawDays > 0 {
+			before := now.Add(-days(retention.RawDays))
+			n, err := inBatches(dbWorker, func(db *persist.DB) (int, error) {
+				return db.CompactResults(check.ID, before, retentionBatch)
+			})
+			if err != nil {
+				return err
+			}
+			compacted += n
+		}
+	}
+	if retention.KeepDays > 0 {
+		err := <-dbWorker.AddWork(func(db *persist.DB) error {
+			return db.PruneEvents(now.Add(-days(retention.KeepDays)))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if pruned > 0 || compacted > 0 {
+		InfoLog.Printf("Retention: pruned %d results and compacted %d results", pruned, compacted)
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/rymdhund/whazza/internal/logging"
+	"github.com/rymdhund/whazza/internal/persist"
+	"github.com/rymdhund/whazza/internal/utils"
+)
+
+// silenceRequest is a silence as given on the command line or posted to the api.
+// Times are RFC3339 or "2006-01-02 15:04" in local time, durations are like "2h" or "1d".
+type silenceRequest struct {
+	Namespace string `json:"namespace"`
+	Agent     string `json:"agent"`
+	CheckID   int    `json:"check_id"`
+	Start     string `json:"start"`
+	End       string `json:"end"`
+	For       string `json:"for"`
+	Schedule  string `json:"schedule"`
+	Duration  string `json:"duration"`
+	Comment   string `json:"comment"`
+}
+
+type silenceJson struct {
+	ID        int        `json:"id"`
+	Namespace string     `json:"namespace,omitempty"`
+	Agent     string     `json:"agent,omitempty"`
+	CheckID   int        `json:"check_id,omitempty"`
+	Start     time.Time  `json:"start"`
+	End       *time.Time `json:"end"`
+	Schedule  string     `json:"schedule,omitempty"`
+	Duration  string     `json:"duration,omitempty"`
+	Comment   string     `json:"comment"`
+	CreatedBy string     `json:"created_by"`
+	Created   time.Time  `json:"created"`
+	Active    bool       `json:"active"`
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+}
+
+func (r silenceRequest) toModel(now time.Time, createdBy string) (persist.SilenceModel, error) {
+	s := persist.SilenceModel{
+		Namespace: r.Namespace,
+		Agent:     r.Agent,
+		CheckID:   r.CheckID,
+		Start:     now,
+		Schedule:  r.Schedule,
+		Comment:   r.Comment,
+		CreatedBy: createdBy,
+		Created:   now,
+	}
+	var err error
+	if r.Start != "" {
+		s.Start, err = parseTime(r.Start)
+		if err != nil {
+			return s, fmt.Errorf("Invalid start: %w", err)
+		}
+	}
+	if r.End != "" && r.For != "" {
+		return s, errors.New("Use only one of end and for")
+	}
+	if r.End != "" {
+		s.End, err = parseTime(r.End)
+		if err != nil {
+			return s, fmt.Errorf("Invalid end: %w", err)
+		}
+	}
+	if r.For != "" {
+		d, err := utils.ParseDuration(r.For)
+		if err != nil {
+			return s, fmt.Errorf("Invalid for: %w", err)
+		}
+		s.End = s.Start.Add(d)
+	}
+	if r.Duration != "" {
+		s.Duration, err = utils.ParseDuration(r.Duration)
+		if err != nil {
+			return s, fmt.Errorf("Invalid duration: %w", err)
+		}
+	}
+	return s, s.Validate()
+}
+
+func mkSilenceJson(s persist.SilenceModel, now time.Time) silenceJson {
+	sj := silenceJson{
+		ID:        s.ID,
+		Namespace: s.Namespace,
+		Agent:     s.Agent,
+		CheckID:   s.CheckID,
+		Start:     s.Start,
+		Schedule:  s.Schedule,
+		Comment:   s.Comment,
+		CreatedBy: s.CreatedBy,
+		Created:   s.Created,
+		Active:    s.ActiveAt(now),
+	}
+	if !s.End.IsZero() {
+		end := s.End
+		sj.End = &end
+	}
+	if s.Duration != 0 {
+		sj.Duration = s.Duration.String()
+	}
+	return sj
+}
+
+func showSilence(s persist.SilenceModel, now time.Time) string {
+	when := ""
+	if s.Schedule == "" {
+		when = fmt.Sprintf("%s to %s", s.Start.Format("2006-01-02 15:04"), s.End.Format("2006-01-02 15:04"))
+	} else {
+		when = fmt.Sprintf("%q for %s", s.Schedule, s.Duration)
+		if !s.End.IsZero() {
+			when += fmt.Sprintf(" until %s", s.End.Format("2006-01-02 15:04"))
+		}
+	}
+	state := ""
+	if s.Expired(now) {
+		state = " (expired)"
+	} else if s.ActiveAt(now) {
+		state = " (active)"
+	}
+	return fmt.Sprintf("%d: %s | %s%s | %s: %s", s.ID, s.Scope(), when, state, s.CreatedBy, s.Comment)
+}
+
+func silenceCommand(args []string) {
+	db, err := persist.Open(Config.Database())
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+	err = db.Init()
+	if err != nil {
+		panic(err)
+	}
+	now := time.Now().Truncate(time.Second)
+
+	switch {
+	case len(args) >= 1 && args[0] == "add":
+		var req silenceRequest
+		fs := flag.NewFlagSet("silence add", flag.ExitOnError)
+		fs.StringVar(&req.Namespace, "namespace", "", "namespace glob pattern, eg 'db:*'")
+		fs.StringVar(&req.Agent, "agent", "", "agent name")
+		fs.IntVar(&req.CheckID, "check", 0, "check id")
+		fs.StringVar(&req.Start, "start", "", "start time (default now)")
+		fs.StringVar(&req.End, "end", "", "end time")
+		fs.StringVar(&req.For, "for", "", "how long the silence lasts, eg 2h")
+		fs.StringVar(&req.Schedule, "cron", "", "recurring cron schedule, eg '0 3 * * 0'")
+		fs.StringVar(&req.Duration, "duration", "", "duration of each recurring silence, eg 1h")
+		fs.StringVar(&req.Comment, "comment", "", "why the checks are silenced")
+		fs.Parse(args[1:])
+
+		s, err := req.toModel(now, cliUser())
+		if err != nil {
+			fmt.Printf("Invalid silence: %s\n", err)
+			os.Exit(1)
+		}
+		s, err = db.AddSilence(s)
+		if err != nil {
+			fmt.Printf("Couldn't add silence: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(showSilence(s, now))
+	case (len(args) == 1 || len(args) == 2) && args[0] == "list":
+		all := len(args) == 2 && args[1] == "--all"
+		if len(args) == 2 && !all {
+			showUsage()
+			os.Exit(1)
+		}
+		silences, err := db.GetSilences(all, now)
+		if err != nil {
+			panic(err)
+		}
+		for _, s := range silences {
+			fmt.Println(showSilence(s, now))
+		}
+	case len(args) == 2 && args[0] == "expire":
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Printf("Invalid silence id: %s\n", args[1])
+			os.Exit(1)
+		}
+		err = db.ExpireSilence(id, cliUser(), now)
+		if err != nil {
+			fmt.Printf("Couldn't expire silence: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Expired silence %d\n", id)
+	default:
+		showUsage()
+		os.Exit(1)
+	}
+}
+
+// apiSilencesHandler lists silences on GET and creates a silence on POST
+func apiSilencesHandler(w http.ResponseWriter, r *http.Request, user persist.UserModel) {
+	now := time.Now().Truncate(time.Second)
+	switch r.Method {
+	case "GET":
+		withDb(w, func(db *persist.DB) {
+			silences, err := db.GetSilences(r.URL.Query().Get("all") != "", now)
+			if err != nil {
+				ErrorLog.Printf("Couldn't get silences: %s", err)
+				http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			silencesJson := make([]silenceJson, 0, len(silences))
+			for _, s := range silences {
+				silencesJson = append(silencesJson, mkSilenceJson(s, now))
+			}
+			writeJson(w, silencesJson)
+		})
+	case "POST":
+		if !user.HasRole(persist.RoleOperator) {
+			http.Error(w, "403 Forbidden", http.StatusForbidden)
+			return
+		}
+		var req silenceRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, "400 Bad Request. Invalid data", http.StatusBadRequest)
+			return
+		}
+		s, err := req.toModel(now, user.Name)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("400 Bad Request. %s", err), http.StatusBadRequest)
+			return
+		}
+		withDb(w, func(db *persist.DB) {
+			s, err := db.AddSilence(s)
+			if err != nil {
+				ErrorLog.Printf("Couldn't add silence: %s", err)
+				http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			InfoLog.Printf("User %s added silence %d for %s", user.Name, s.ID, s.Scope())
+			w.WriteHeader(http.StatusCreated)
+			writeJson(w, mkSilenceJson(s, now))
+		})
+	default:
+		http.Error(w, "405 Method Not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// apiSilenceHandler expires the silence /api/silences/{id} on DELETE
+func apiSilenceHandler(w http.ResponseWriter, r *http.Request, user persist.UserModel) {
+	if r.Method != "DELETE" {
+		http.Error(w, "405 Method Not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !user.HasRole(persist.RoleOperator) {
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/silences/"))
+	if err != nil {
+		notFoundHandler(w, r)
+		return
+	}
+	withDb(w, func(db *persist.DB) {
+		err := db.ExpireSilence(id, user.Name, time.Now())
+		if errors.Is(err, persist.ErrSilenceNotFound) {
+			notFoundHandler(w, r)
+			return
+		} else if err != nil {
+			ErrorLog.Printf("Couldn't expire silence %d: %s", id, err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		InfoLog.Printf("User %s expired silence %d", user.Name, id)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
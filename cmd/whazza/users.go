@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/rymdhund/whazza/internal/password"
+	"github.com/rymdhund/whazza/internal/persist"
+)
+
+func userCommand(args []string) {
+	db, err := persist.Open(Config.Database())
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+	err = db.Init()
+	if err != nil {
+		panic(err)
+	}
+
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		users, err := db.GetUsers()
+		if err != nil {
+			panic(err)
+		}
+		for _, u := range users {
+			fmt.Printf("%s (%s)\n", u.Name, u.Role)
+		}
+	case len(args) == 3 && args[0] == "add":
+		if !persist.ValidRole(args[2]) {
+			fmt.Printf("Invalid role: %s. Use %s or %s\n", args[2], persist.RoleViewer, persist.RoleOperator)
+			os.Exit(1)
+		}
+		pw := readNewPassword()
+		_, err := db.AddUser(args[1], password.Hash(pw), args[2])
+		if err != nil {
+			fmt.Printf("Couldn't add user: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Added user %s\n", args[1])
+	case len(args) == 2 && args[0] == "remove":
+		err := db.RemoveUser(args[1])
+		if err != nil {
+			fmt.Printf("Couldn't remove user: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed user %s\n", args[1])
+	case len(args) == 2 && args[0] == "passwd":
+		if _, err := db.GetUser(args[1]); err != nil {
+			fmt.Printf("Couldn't change password: %s\n", err)
+			os.Exit(1)
+		}
+		pw := readNewPassword()
+		err := db.SetPassword(args[1], password.Hash(pw))
+		if err != nil {
+			fmt.Printf("Couldn't change password: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Changed password for %s\n", args[1])
+	case len(args) >= 2 && args[0] == "token":
+		tokenCommand(db, args[1:])
+	default:
+		showUsage()
+		os.Exit(1)
+	}
+}
+
+func tokenCommand(db *persist.DB, args []string) {
+	switch {
+	case (len(args) == 2 || len(args) == 3) && args[0] == "add":
+		user, err := db.GetUser(args[1])
+		if err != nil {
+			fmt.Printf("Couldn't create token: %s\n", err)
+			os.Exit(1)
+		}
+		name := "api"
+		if len(args) == 3 {
+			name = args[2]
+		}
+		token, err := db.CreateApiToken(user, name)
+		if err != nil {
+			fmt.Printf("Couldn't create token: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Token: %s\n", token)
+		fmt.Printf("Use it with: curl --insecure -H 'Authorization: Bearer %s' https://localhost:%d/api/checks\n", token, Config.Port)
+	case len(args) == 2 && args[0] == "list":
+		user, err := db.GetUser(args[1])
+		if err != nil {
+			fmt.Printf("Couldn't list tokens: %s\n", err)
+			os.Exit(1)
+		}
+		tokens, err := db.GetApiTokens(user)
+		if err != nil {
+			panic(err)
+		}
+		for _, t := range tokens {
+			fmt.Printf("%d %s (created %s)\n", t.ID, t.Name, t.Created.Format("2006-01-02 15:04"))
+		}
+	case len(args) == 3 && args[0] == "revoke":
+		user, err := db.GetUser(args[1])
+		if err != nil {
+			fmt.Printf("Couldn't revoke token: %s\n", err)
+			os.Exit(1)
+		}
+		id, err := strconv.Atoi(args[2])
+		if err != nil {
+			fmt.Printf("Invalid token id: %s\n", args[2])
+			os.Exit(1)
+		}
+		err = db.RevokeApiToken(user, id)
+		if err != nil {
+			fmt.Printf("Couldn't revoke token: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Revoked token %d of %s\n", id, args[1])
+	default:
+		showUsage()
+		os.Exit(1)
+	}
+}
+
+func readNewPassword() string {
+	pw, err := readPassword("Password: ")
+	if err != nil {
+		fmt.Printf("Couldn't read password: %s\n", err)
+		os.Exit(1)
+	}
+	again, err := readPassword("Repeat password: ")
+	if err != nil {
+		fmt.Printf("Couldn't read password: %s\n", err)
+		os.Exit(1)
+	}
+	if pw != again {
+		fmt.Println("Passwords don't match")
+		os.Exit(1)
+	}
+	if len(pw) < 8 {
+		fmt.Println("Password must be at least 8 characters")
+		os.Exit(1)
+	}
+	return pw
+}
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// readPassword reads a line from stdin, turning off echo if stdin is a terminal
+func readPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice != 0 {
+		if stty("-echo") == nil {
+			defer func() {
+				stty("echo")
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+	line, err := stdinReader.ReadString('\n')
+	// Accept a last line without newline
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func stty(arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
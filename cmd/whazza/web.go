@@ -0,0 +1,231 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/rymdhund/whazza/internal/logging"
+	"github.com/rymdhund/whazza/internal/persist"
+	"github.com/rymdhund/whazza/internal/utils"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+// Seconds between automatic reloads of the web pages
+const webRefresh = 30
+
+// Max number of results shown in the check history
+const maxHistory = 500
+
+var historyPeriods = []string{"1h", "24h", "7d", "30d"}
+
+type pageData struct {
+	Title   string
+	Refresh int
+	Now     time.Time
+	User    string
+}
+
+type webCheck struct {
+	ID           int
+	Agent        string
+	Namespace    string
+	Type         string
+	Title        string
+	Interval     int
+	Status       string
+	Msg          string
+	LastReceived string
+	LastGood     string
+	LastFail     string
+	Silenced     bool
+	Ack          *persist.AckModel
+	// Retired is empty for active checks
+	Retired string
+}
+
+type webAgent struct {
+	Name   string
+	Checks []webCheck
+}
+
+type webNamespace struct {
+	Name   string
+	Agents []*webAgent
+}
+
+type statusCount struct {
+	Status string
+	Count  int
+}
+
+func mkWebCheck(o persist.CheckOverview, now time.Time) webCheck {
+	check := o.CheckModel.Check
+	c := webCheck{
+		ID:           o.CheckModel.ID,
+		Agent:        o.CheckModel.Agent.Name,
+		Namespace:    check.Namespace,
+		Type:         check.Type,
+		Title:        check.Title(),
+		Interval:     check.Interval,
+		Status:       o.Result.Status,
+		Msg:          o.Result.Msg,
+		LastReceived: utils.HumanRelTime(now, o.LastReceived.Timestamp, false),
+		LastGood:     utils.HumanRelTime(now, o.LastGood.Timestamp, false),
+		LastFail:     utils.HumanRelTime(now, o.LastFail.Timestamp, false),
+		Silenced:     o.Silenced,
+		Ack:          o.Ack,
+	}
+	if !o.CheckModel.Retired.IsZero() {
+		c.Retired = utils.HumanRelTime(now, o.CheckModel.Retired, false)
+	}
+	return c
+}
+
+// groupOverviews groups checks by namespace and agent, sorted by name
+func groupOverviews(overviews []persist.CheckOverview, now time.Time) []*webNamespace {
+	namespaces := map[string]*webNamespace{}
+	agents := map[[2]string]*webAgent{}
+	for _, o := range overviews {
+		c := mkWebCheck(o, now)
+		ns, ok := namespaces[c.Namespace]
+		if !ok {
+			ns = &webNamespace{Name: c.Namespace}
+			namespaces[c.Namespace] = ns
+		}
+		key := [2]string{c.Namespace, c.Agent}
+		agent, ok := agents[key]
+		if !ok {
+			agent = &webAgent{Name: c.Agent}
+			agents[key] = agent
+			ns.Agents = append(ns.Agents, agent)
+		}
+		agent.Checks = append(agent.Checks, c)
+	}
+
+	grouped := make([]*webNamespace, 0, len(namespaces))
+	for _, ns := range namespaces {
+		sort.Slice(ns.Agents, func(i, j int) bool { return ns.Agents[i].Name < ns.Agents[j].Name })
+		for _, agent := range ns.Agents {
+			sort.Slice(agent.Checks, func(i, j int) bool { return agent.Checks[i].Title < agent.Checks[j].Title })
+		}
+		grouped = append(grouped, ns)
+	}
+	sort.Slice(grouped, func(i, j int) bool { return grouped[i].Name < grouped[j].Name })
+	return grouped
+}
+
+func summarize(overviews []persist.CheckOverview) []statusCount {
+	counts := map[string]int{}
+	for _, o := range overviews {
+		counts[o.Result.Status]++
+	}
+	summary := []statusCount{}
+	for _, status := range []string{"fail", "error", "expired", "warning", "good"} {
+		if counts[status] > 0 {
+			summary = append(summary, statusCount{status, counts[status]})
+		}
+	}
+	return summary
+}
+
+func renderTemplate(w http.ResponseWriter, name string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := templates.ExecuteTemplate(w, name, data)
+	if err != nil {
+		ErrorLog.Printf("Couldn't render %s: %s", name, err)
+	}
+}
+
+func dashboardHandler(w http.ResponseWriter, r *http.Request, user persist.UserModel) {
+	withDb(w, func(db *persist.DB) {
+		overviews, err := db.GetCheckOverviews(false)
+		if err != nil {
+			ErrorLog.Printf("Couldn't get check overviews: %s", err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		now := time.Now()
+		renderTemplate(w, "dashboard.html", struct {
+			pageData
+			Summary    []statusCount
+			Namespaces []*webNamespace
+		}{
+			pageData{"Overview", webRefresh, now, user.Name},
+			summarize(overviews),
+			groupOverviews(overviews, now),
+		})
+	})
+}
+
+// checkPageHandler serves /checks/{id} with the result history of a check
+func checkPageHandler(w http.ResponseWriter, r *http.Request, user persist.UserModel) {
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/checks/"))
+	if err != nil {
+		notFoundHandler(w, r)
+		return
+	}
+	period := r.URL.Query().Get("since")
+	if period == "" {
+		period = "24h"
+	}
+	dur, err := utils.ParseDuration(period)
+	if err != nil {
+		http.Error(w, "400 Bad Request. Invalid since", http.StatusBadRequest)
+		return
+	}
+
+	withDb(w, func(db *persist.DB) {
+		checkModel, err := db.GetCheckById(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			notFoundHandler(w, r)
+			return
+		} else if err != nil {
+			ErrorLog.Printf("Couldn't get check %d: %s", id, err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		overview, err := db.GetCheckOverview(checkModel)
+		if err != nil {
+			ErrorLog.Printf("Couldn't get check overview %d: %s", id, err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		now := time.Now()
+		results, err := db.GetResults(id, now.Add(-dur))
+		if err != nil {
+			ErrorLog.Printf("Couldn't get results for check %d: %s", id, err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		// Newest first
+		history := []persist.ResultModel{}
+		for i := len(results) - 1; i >= 0 && len(history) < maxHistory; i-- {
+			history = append(history, results[i])
+		}
+
+		check := mkWebCheck(overview, now)
+		renderTemplate(w, "check.html", struct {
+			pageData
+			Check   webCheck
+			Periods []string
+			Results []persist.ResultModel
+		}{
+			pageData{check.Title, webRefresh, now, user.Name},
+			check,
+			historyPeriods,
+			history,
+		})
+	})
+}
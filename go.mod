@@ -2,4 +2,7 @@ module github.com/rymdhund/whazza
 
 go 1.19
 
-require github.com/mattn/go-sqlite3 v1.14.16
+require (
+	github.com/mattn/go-sqlite3 v1.14.16
+	golang.org/x/crypto v0.24.0
+)
@@ -20,6 +20,8 @@ type Config struct {
 	ServerCertFingerprint string `json:"server_cert_fingerprint"`
 	AgentName             string `json:"agent_name"`
 	AgentToken            string `json:"agent_token"`
+	// Max number of results kept while the hub can't be reached, defaults to DefaultQueueSize
+	QueueSize int `json:"queue_size,omitempty"`
 }
 
 func GenerateConfig(agentName string, serverHost string, serverPort int, serverFingerprint string) (Config, error) {
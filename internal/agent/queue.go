@@ -0,0 +1,184 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rymdhund/whazza/internal/messages"
+)
+
+// Default max number of results in the queue
+const DefaultQueueSize = 10000
+
+// Number of removed results we allow at the start of the queue file before rewriting it
+const queueCompactAfter = 100
+
+// ResultQueue holds results waiting to be sent to the hub. It is stored in a file with one json result per
+// line so that it survives restarts. Removed results are only cleared from the file now and then, until then
+// their number is kept in a second file. After a crash a result may be sent twice, but never lost.
+type ResultQueue struct {
+	mu       sync.Mutex
+	filename string
+	maxSize  int
+	results  []messages.CheckResultMsg
+	// Number of results at the start of the file that are no longer in the queue
+	removed int
+	// Sequence number of the first result in the queue, counting from when the queue was opened
+	head int
+}
+
+// OpenResultQueue reads the queue from the file, which is created if needed. Lines that can't be read,
+// like a half written line after a crash, are skipped. If the queue is over its max size the oldest
+// results are dropped.
+func OpenResultQueue(filename string, maxSize int) (*ResultQueue, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultQueueSize
+	}
+	q := &ResultQueue{filename: filename, maxSize: maxSize}
+
+	f, err := os.Open(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		defer f.Close()
+		// A missing or broken position file means that nothing is removed
+		removed := 0
+		if pos, err := ioutil.ReadFile(q.posFilename()); err == nil {
+			removed, _ = strconv.Atoi(strings.TrimSpace(string(pos)))
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 1024*1024)
+		for line := 0; scanner.Scan(); line++ {
+			var msg messages.CheckResultMsg
+			if line < removed || json.Unmarshal(scanner.Bytes(), &msg) != nil {
+				continue
+			}
+			q.results = append(q.results, msg)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if len(q.results) > maxSize {
+		q.results = q.results[len(q.results)-maxSize:]
+	}
+	// Start from a clean file
+	return q, q.rewrite()
+}
+
+func (q *ResultQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.results)
+}
+
+// Push adds a result to the end of the queue. If the queue is full the oldest result is dropped and
+// dropped is true.
+func (q *ResultQueue) Push(msg messages.CheckResultMsg) (dropped bool, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return false, err
+	}
+	if len(q.results) >= q.maxSize {
+		q.results = q.results[1:]
+		q.removed++
+		q.head++
+		dropped = true
+	}
+	q.results = append(q.results, msg)
+
+	if q.removed >= queueCompactAfter {
+		return dropped, q.rewrite()
+	}
+	if dropped {
+		if err := q.writePos(); err != nil {
+			return dropped, err
+		}
+	}
+	f, err := os.OpenFile(q.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return dropped, err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return dropped, err
+}
+
+// Peek returns up to n results from the start of the queue and the sequence number of the first of them
+func (q *ResultQueue) Peek(n int) (first int, results []messages.CheckResultMsg) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if n > len(q.results) {
+		n = len(q.results)
+	}
+	return q.head, append([]messages.CheckResultMsg{}, q.results[:n]...)
+}
+
+// Remove removes the n results from sequence number first that are still in the queue. Results that
+// were dropped since they were peeked don't count, so that no other results are removed in their place.
+func (q *ResultQueue) Remove(first, n int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n = first + n - q.head
+	if n <= 0 {
+		return nil
+	}
+	if n > len(q.results) {
+		n = len(q.results)
+	}
+	q.results = q.results[n:]
+	q.removed += n
+	q.head += n
+	if len(q.results) == 0 || q.removed >= queueCompactAfter {
+		return q.rewrite()
+	}
+	return q.writePos()
+}
+
+func (q *ResultQueue) posFilename() string {
+	return q.filename + ".pos"
+}
+
+// writePos stores the number of removed results at the start of the file
+func (q *ResultQueue) writePos() error {
+	return ioutil.WriteFile(q.posFilename(), []byte(strconv.Itoa(q.removed)), 0600)
+}
+
+// rewrite writes the queued results to a new file that replaces the old one
+func (q *ResultQueue) rewrite() error {
+	tmp := q.filename + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, msg := range q.results {
+		if err := enc.Encode(msg); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// Reset the position first, if we crash before the rename the removed results are sent again
+	q.removed = 0
+	if err := q.writePos(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.filename)
+}
@@ -0,0 +1,184 @@
+package agent
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/base"
+	"github.com/rymdhund/whazza/internal/chk"
+	. "github.com/rymdhund/whazza/internal/logging"
+	"github.com/rymdhund/whazza/internal/messages"
+)
+
+func TestMain(m *testing.M) {
+	DebugLog = log.New(ioutil.Discard, "", 0)
+	InfoLog = log.New(ioutil.Discard, "", 0)
+	WarningLog = log.New(ioutil.Discard, "", 0)
+	ErrorLog = log.New(ioutil.Discard, "", 0)
+	os.Exit(m.Run())
+}
+
+func mkResult(t *testing.T, i int) messages.CheckResultMsg {
+	check, err := chk.New("http-up", "web", 60, []byte(`{"host": "example.com"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return messages.NewCheckResultMsg(check, base.Result{Status: "good", Timestamp: time.Unix(1600000000+int64(i), 0)})
+}
+
+func TestResultQueue(t *testing.T) {
+	filename := path.Join(t.TempDir(), "queue.jsonl")
+	q, err := OpenResultQueue(filename, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		dropped, err := q.Push(mkResult(t, i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dropped != (i == 3) {
+			t.Errorf("Unexpected dropped %v for result %d", dropped, i)
+		}
+	}
+	if err := q.Remove(1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// A half written line is skipped
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"Check": {"type": "ht`)
+	f.Close()
+
+	q, err = OpenResultQueue(filename, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, pending := q.Peek(5)
+	if len(pending) != 2 || !pending[0].Result.Timestamp.Equal(time.Unix(1600000002, 0)) || !pending[1].Result.Timestamp.Equal(time.Unix(1600000003, 0)) {
+		t.Fatalf("Unexpected queue after reopen %+v", pending)
+	}
+	if pending[0].Check.Title() != "http:example.com" {
+		t.Fatalf("Unexpected check %+v", pending[0].Check)
+	}
+	if err := q.Remove(first, 2); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filename); err != nil || info.Size() != 0 {
+		t.Fatalf("Expected an empty queue file, got %v %v", info, err)
+	}
+}
+
+func TestResultQueueDropWhileSending(t *testing.T) {
+	q, err := OpenResultQueue(path.Join(t.TempDir(), "queue.jsonl"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := q.Push(mkResult(t, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sent := make(chan struct{})
+	release := make(chan struct{})
+	s := NewSender(func(msgs []messages.CheckResultMsg) ([]messages.ResultStatusMsg, error) {
+		sent <- struct{}{}
+		<-release
+		statuses := make([]messages.ResultStatusMsg, len(msgs))
+		for i := range statuses {
+			statuses[i].OK = true
+		}
+		return statuses, nil
+	}, q)
+	s.batchDelay = time.Millisecond
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		s.Run(stop)
+		close(done)
+	}()
+	<-sent
+	// The full queue drops the oldest result while the batch is being sent
+	s.Add(mkResult(t, 3))
+	s.Add(mkResult(t, 4))
+	release <- struct{}{}
+
+	// Only the sent results are removed, the new ones are sent next
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the new results to be sent, %d results queued", q.Len())
+	}
+	_, pending := q.Peek(5)
+	if len(pending) != 2 || pending[0].Result.Timestamp.Unix() != 1600000003 || pending[1].Result.Timestamp.Unix() != 1600000004 {
+		t.Fatalf("Unexpected queue %+v", pending)
+	}
+	release <- struct{}{}
+
+	for i := 0; i < 100 && q.Len() > 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	<-done
+}
+
+func TestSenderRetriesInOrder(t *testing.T) {
+	q, err := OpenResultQueue(path.Join(t.TempDir(), "queue.jsonl"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failures := 2
+	batches := make(chan []messages.CheckResultMsg, 10)
+	s := NewSender(func(msgs []messages.CheckResultMsg) ([]messages.ResultStatusMsg, error) {
+		if failures > 0 {
+			failures--
+			return nil, errors.New("Connection refused")
+		}
+		statuses := make([]messages.ResultStatusMsg, len(msgs))
+		for i, msg := range msgs {
+			statuses[i].OK = msg.Result.Timestamp.Unix() != 1600000001
+		}
+		batches <- msgs
+		return statuses, nil
+	}, q)
+	s.batchDelay = 10 * time.Millisecond
+	s.minBackoff = time.Millisecond
+	s.maxBackoff = 2 * time.Millisecond
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.Run(stop)
+	for i := 0; i < 3; i++ {
+		s.Add(mkResult(t, i))
+	}
+
+	select {
+	case batch := <-batches:
+		if len(batch) != 3 {
+			t.Fatalf("Expected one batch of 3 results, got %d", len(batch))
+		}
+		for i, msg := range batch {
+			if msg.Result.Timestamp.Unix() != 1600000000+int64(i) {
+				t.Errorf("Unexpected result %d from %s", i, msg.Result.Timestamp)
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for results")
+	}
+	// Rejected results are dropped too
+	for i := 0; i < 100 && q.Len() > 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if q.Len() != 0 {
+		t.Fatalf("Expected an empty queue, got %d results", q.Len())
+	}
+}
@@ -4,15 +4,26 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 
+	"github.com/rymdhund/whazza/internal/chk"
 	"github.com/rymdhund/whazza/internal/messages"
 	"github.com/rymdhund/whazza/internal/tofu"
 )
 
+// StatusError is returned when the hub responds with an unexpected http status
+type StatusError struct {
+	Code int
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("Unexpected status: %d", e.Code)
+}
+
 type HubConnection struct {
 	client *http.Client
 	cfg    Config
@@ -91,7 +102,103 @@ func (conn *HubConnection) SendCheckResult(cfg Config, msg messages.CheckResultM
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("Unexpected status: %d", resp.StatusCode)
+		return StatusError{resp.StatusCode}
 	}
 	return nil
 }
+
+// SendCheckResults sends a batch of results and returns the status of each of them. Hubs without
+// the batch endpoint get the results one at a time. On errors the statuses of the results that were
+// handled before the error are returned.
+func (conn *HubConnection) SendCheckResults(cfg Config, msgs []messages.CheckResultMsg) ([]messages.ResultStatusMsg, error) {
+	payload, err := json.Marshal(msgs)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := conn.request("POST", "/agent/results", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		io.Copy(ioutil.Discard, resp.Body)
+		return conn.sendOneByOne(cfg, msgs)
+	}
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil, StatusError{resp.StatusCode}
+	}
+
+	var statuses []messages.ResultStatusMsg
+	err = json.NewDecoder(resp.Body).Decode(&statuses)
+	if err != nil {
+		return nil, err
+	}
+	if len(statuses) != len(msgs) {
+		return nil, fmt.Errorf("Got %d statuses for %d results", len(statuses), len(msgs))
+	}
+	return statuses, nil
+}
+
+func (conn *HubConnection) sendOneByOne(cfg Config, msgs []messages.CheckResultMsg) ([]messages.ResultStatusMsg, error) {
+	statuses := make([]messages.ResultStatusMsg, len(msgs))
+	for i, msg := range msgs {
+		err := conn.SendCheckResult(cfg, msg)
+		var statusErr StatusError
+		if errors.As(err, &statusErr) && statusErr.Code == http.StatusBadRequest {
+			statuses[i].Error = err.Error()
+		} else if err != nil {
+			return statuses[:i], err
+		} else {
+			statuses[i].OK = true
+		}
+	}
+	return statuses, nil
+}
+
+// GetChecks returns the checks of this agent that the hub knows about
+func (conn *HubConnection) GetChecks() ([]messages.CheckStateMsg, error) {
+	resp, err := conn.request("GET", "/agent/checks", nil)
+	if err != nil {
+		return nil, err
+	}
+	return readCheckStates(resp)
+}
+
+// ReportChecks tells the hub which checks this agent runs, so that it can retire the others, and
+// returns the checks the hub knows about like GetChecks. Hubs that can't retire checks are only asked
+// for their checks.
+func (conn *HubConnection) ReportChecks(checks []chk.Check) ([]messages.CheckStateMsg, error) {
+	payload, err := json.Marshal(checks)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := conn.request("POST", "/agent/checks", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusMethodNotAllowed {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		return conn.GetChecks()
+	}
+	return readCheckStates(resp)
+}
+
+// readCheckStates reads and closes a response with check states
+func readCheckStates(resp *http.Response) ([]messages.CheckStateMsg, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil, StatusError{resp.StatusCode}
+	}
+	var states []messages.CheckStateMsg
+	err := json.NewDecoder(resp.Body).Decode(&states)
+	if err != nil {
+		return nil, err
+	}
+	return states, nil
+}
@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"time"
+
+	"github.com/rymdhund/whazza/internal/chk"
+	"github.com/rymdhund/whazza/internal/messages"
+)
+
+// CheckKey identifies a check like the hub does, by its type, namespace and checker parameters
+func CheckKey(check chk.Check) string {
+	return check.Type + "\x00" + check.Namespace + "\x00" + string(check.Checker.AsJson())
+}
+
+// LastRuns returns when each check last ran by check key, from the checks known by the hub and the
+// results that are still queued
+func LastRuns(states []messages.CheckStateMsg, queued []messages.CheckResultMsg) map[string]time.Time {
+	lastRuns := map[string]time.Time{}
+	update := func(check chk.Check, t time.Time) {
+		key := CheckKey(check)
+		if t.After(lastRuns[key]) {
+			lastRuns[key] = t
+		}
+	}
+	for _, s := range states {
+		update(s.Check, s.LastRun)
+	}
+	for _, msg := range queued {
+		update(msg.Check, msg.Result.Timestamp)
+	}
+	return lastRuns
+}
+
+// FirstRun returns when a check should run first after the agent starts, an interval after its last
+// run or now if that has passed. A last run in the future, eg due to clock skew, counts as now.
+func FirstRun(check chk.Check, lastRun time.Time, now time.Time) time.Time {
+	interval := time.Duration(check.Interval) * time.Second
+	next := lastRun.Add(interval)
+	if next.Before(now) {
+		return now
+	}
+	if next.After(now.Add(interval)) {
+		return now.Add(interval)
+	}
+	return next
+}
+
+// ReloadSchedule returns when each of the reloaded checks should run next, given the next runs of the
+// checks that were scheduled before by check key. Checks that were already scheduled keep their next
+// run, but wait at most their new interval. New checks run now.
+func ReloadSchedule(scheduled map[string]time.Time, checks []chk.Check, now time.Time) []time.Time {
+	next := make([]time.Time, len(checks))
+	for i, c := range checks {
+		t, ok := scheduled[CheckKey(c)]
+		interval := time.Duration(c.Interval) * time.Second
+		switch {
+		case !ok || t.Before(now):
+			next[i] = now
+		case t.After(now.Add(interval)):
+			next[i] = now.Add(interval)
+		default:
+			next[i] = t
+		}
+	}
+	return next
+}
@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/base"
+	"github.com/rymdhund/whazza/internal/chk"
+	"github.com/rymdhund/whazza/internal/messages"
+)
+
+func TestLastRunsAndFirstRun(t *testing.T) {
+	web, _ := chk.New("http-up", "web", 60, []byte(`{"host": "example.com"}`))
+	other, _ := chk.New("http-up", "web", 60, []byte(`{"host": "example.org"}`))
+	never, _ := chk.New("http-up", "db", 60, []byte(`{"host": "example.com"}`))
+	now := time.Unix(1600000000, 0)
+
+	states := []messages.CheckStateMsg{
+		{Check: web, LastRun: now.Add(-50 * time.Second)},
+		{Check: other, LastRun: now.Add(-2 * time.Hour)},
+		{Check: never},
+	}
+	queued := []messages.CheckResultMsg{
+		messages.NewCheckResultMsg(other, base.Result{Status: "fail", Timestamp: now.Add(-30 * time.Second)}),
+	}
+	lastRuns := LastRuns(states, queued)
+
+	cases := []struct {
+		check    chk.Check
+		expected time.Time
+	}{
+		{web, now.Add(10 * time.Second)},
+		{other, now.Add(30 * time.Second)},
+		{never, now},
+	}
+	for _, c := range cases {
+		if next := FirstRun(c.check, lastRuns[CheckKey(c.check)], now); !next.Equal(c.expected) {
+			t.Errorf("Expected %s to run at %s, got %s", c.check.Title(), c.expected, next)
+		}
+	}
+
+	// Last runs in the future wait at most an interval
+	if next := FirstRun(web, now.Add(time.Hour), now); !next.Equal(now.Add(time.Minute)) {
+		t.Errorf("Expected a run in a minute, got %s", next)
+	}
+}
+
+func TestReloadSchedule(t *testing.T) {
+	web, _ := chk.New("http-up", "web", 60, []byte(`{"host": "example.com"}`))
+	slow, _ := chk.New("http-up", "web", 600, []byte(`{"host": "example.org"}`))
+	added, _ := chk.New("http-up", "db", 60, []byte(`{"host": "example.com"}`))
+	removed, _ := chk.New("tcp-port", "db", 60, []byte(`{"host": "example.com", "port": 5432}`))
+	now := time.Unix(1600000000, 0)
+
+	scheduled := map[string]time.Time{
+		CheckKey(web):     now.Add(20 * time.Second),
+		CheckKey(slow):    now.Add(500 * time.Second),
+		CheckKey(removed): now.Add(10 * time.Second),
+	}
+	// The slow check got a shorter interval
+	fast := slow
+	fast.Interval = 60
+
+	next := ReloadSchedule(scheduled, []chk.Check{web, fast, added}, now)
+	expected := []time.Time{now.Add(20 * time.Second), now.Add(time.Minute), now}
+	if len(next) != len(expected) {
+		t.Fatalf("Expected %d runs, got %v", len(expected), next)
+	}
+	for i := range expected {
+		if !next[i].Equal(expected[i]) {
+			t.Errorf("Expected run %d at %s, got %s", i, expected[i], next[i])
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	. "github.com/rymdhund/whazza/internal/logging"
+	"github.com/rymdhund/whazza/internal/messages"
+)
+
+// SendFunc sends a batch of results and returns the status of each of them. On errors it returns the
+// statuses of the results that were handled before the error.
+type SendFunc func([]messages.CheckResultMsg) ([]messages.ResultStatusMsg, error)
+
+// Sender delivers results to the hub in the order they were produced. Results that finish close together
+// are sent in one batch. Results wait in the queue until they are delivered, and delivery is retried with
+// exponential backoff while the hub can't be reached.
+type Sender struct {
+	send       SendFunc
+	queue      *ResultQueue
+	wake       chan struct{}
+	batchDelay time.Duration
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+func NewSender(send SendFunc, queue *ResultQueue) *Sender {
+	return &Sender{
+		send:       send,
+		queue:      queue,
+		wake:       make(chan struct{}, 1),
+		batchDelay: 500 * time.Millisecond,
+		minBackoff: 5 * time.Second,
+		maxBackoff: 5 * time.Minute,
+	}
+}
+
+// Add queues a result for delivery
+func (s *Sender) Add(msg messages.CheckResultMsg) {
+	dropped, err := s.queue.Push(msg)
+	if err != nil {
+		ErrorLog.Printf("Couldn't store result in queue: %s", err)
+	}
+	if dropped {
+		WarningLog.Printf("Result queue is full, dropped the oldest result")
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// rejected returns true if the hub will never accept the results, so that retrying is pointless
+func rejected(err error) bool {
+	var statusErr StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == http.StatusBadRequest
+}
+
+// Run delivers queued results until stop is closed
+func (s *Sender) Run(stop <-chan struct{}) {
+	backoff := s.minBackoff
+	for {
+		first, pending := s.queue.Peek(messages.MaxBatchSize)
+		if len(pending) == 0 {
+			select {
+			case <-s.wake:
+			case <-stop:
+				return
+			}
+			// Wait for more results to batch with
+			select {
+			case <-time.After(s.batchDelay):
+			case <-stop:
+				return
+			}
+			continue
+		}
+
+		statuses, err := s.send(pending)
+		handled := len(statuses)
+		if rejected(err) {
+			// The hub will never accept the batch as it is
+			ErrorLog.Printf("Hub rejected %d results, dropping them: %s", len(pending), err)
+			handled, err = len(pending), nil
+		}
+		for i, status := range statuses {
+			if !status.OK {
+				ErrorLog.Printf("Hub rejected result for %s, dropping it: %s", pending[i].Check.Title(), status.Error)
+			}
+		}
+		if err := s.queue.Remove(first, handled); err != nil {
+			ErrorLog.Printf("Couldn't remove results from queue: %s", err)
+		}
+
+		if err != nil {
+			WarningLog.Printf("Couldn't send results, retrying %d queued results in %s: %s", s.queue.Len(), backoff, err)
+			select {
+			case <-time.After(backoff):
+			case <-stop:
+				return
+			}
+			backoff *= 2
+			if backoff > s.maxBackoff {
+				backoff = s.maxBackoff
+			}
+			continue
+		}
+		backoff = s.minBackoff
+	}
+}
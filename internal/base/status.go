@@ -26,6 +26,24 @@ func FailResult(msg string) Result {
 	}
 }
 
+// WarningResult is for checks that are degraded but not failing, eg a cert expiring in a few weeks
+func WarningResult(msg string) Result {
+	return Result{
+		Status:    "warning",
+		Msg:       msg,
+		Timestamp: time.Now(),
+	}
+}
+
+// ErrorResult is for when the checker itself could not run, eg no network on the agent
+func ErrorResult(msg string) Result {
+	return Result{
+		Status:    "error",
+		Msg:       msg,
+		Timestamp: time.Now(),
+	}
+}
+
 func ExpiredResult() Result {
 	return Result{
 		Status:    "expired",
@@ -41,3 +59,12 @@ func NoDataResult() Result {
 		Timestamp: time.Now(),
 	}
 }
+
+// IsReportable returns true for the statuses an agent may report
+func IsReportable(status string) bool {
+	switch status {
+	case "good", "warning", "fail", "error":
+		return true
+	}
+	return false
+}
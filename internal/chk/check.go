@@ -3,7 +3,9 @@ package chk
 import (
 	"bytes"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"time"
 
@@ -31,6 +33,10 @@ type checkBase struct {
 	Type      string `json:"type"`
 	Namespace string `json:"namespace"`
 	Interval  int    `json:"interval"`
+	// Number of consecutive non-good results before we alert, 0 means 1
+	AlertAfter int `json:"alert_after,omitempty"`
+	// Number of consecutive good results before we notify a recovery, 0 means 1
+	RecoverAfter int `json:"recover_after,omitempty"`
 }
 
 type Checker interface {
@@ -45,6 +51,12 @@ func (c Check) Validate() error {
 	if c.Interval <= 0 {
 		return fmt.Errorf("Invalid interval: %d", c.Interval)
 	}
+	if c.AlertAfter < 0 {
+		return fmt.Errorf("Invalid alert_after: %d", c.AlertAfter)
+	}
+	if c.RecoverAfter < 0 {
+		return fmt.Errorf("Invalid recover_after: %d", c.RecoverAfter)
+	}
 	return c.Checker.Validate()
 }
 
@@ -53,8 +65,13 @@ func (c Check) Title() string {
 }
 
 // IsExpired returns true if the check is expired
-// We count the check as expired if the check is delayed by Interval/2 (but at least 10m and at most 4h)
 func (c Check) IsExpired(lastResult time.Time, now time.Time) bool {
+	return lastResult.Add(c.ExpiryLimit()).Before(now)
+}
+
+// ExpiryLimit is how long after a result the check expires
+// We count the check as expired if the check is delayed by Interval/2 (but at least 10m and at most 4h)
+func (c Check) ExpiryLimit() time.Duration {
 	limit := time.Duration(c.Interval/2) * time.Second
 	if limit.Minutes() < 10 {
 		limit = time.Duration(10) * time.Minute
@@ -62,9 +79,7 @@ func (c Check) IsExpired(lastResult time.Time, now time.Time) bool {
 	if limit.Hours() > 4 {
 		limit = time.Duration(4) * time.Hour
 	}
-	limit += time.Duration(c.Interval) * time.Second
-
-	return lastResult.Add(limit).Before(now)
+	return limit + time.Duration(c.Interval)*time.Second
 }
 
 func New(checkType, namespace string, interval int, checkerJson []byte) (Check, error) {
@@ -88,3 +103,13 @@ func New(checkType, namespace string, interval int, checkerJson []byte) (Check,
 func Equal(c1, c2 Check) bool {
 	return c1.checkBase == c2.checkBase && bytes.Equal(c1.Checker.AsJson(), c2.Checker.AsJson())
 }
+
+// networkErrorResult makes a result from an error when connecting to a host.
+// Dns lookup timeouts are most likely a problem on the agent side so we report those as errors instead of failures.
+func networkErrorResult(err error) base.Result {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && (dnsErr.IsTimeout || dnsErr.IsTemporary) {
+		return base.ErrorResult(err.Error())
+	}
+	return base.FailResult(err.Error())
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"time"
 
 	"github.com/rymdhund/whazza/internal/base"
@@ -15,6 +16,7 @@ type CertChecker struct {
 	Host            string `json:"host"`
 	Port            int    `json:"port,omitempty"`
 	ExpiresSoonDays int    `json:"expires_soon_days,omitempty"`
+	WarnSoonDays    int    `json:"warn_soon_days,omitempty"`
 }
 
 func (c CertChecker) Title() string {
@@ -57,10 +59,21 @@ func (c CertChecker) expiresSoonDaysOrDefault() int {
 	return c.ExpiresSoonDays
 }
 
+func (c CertChecker) warnSoonDaysOrDefault() int {
+	if c.WarnSoonDays == 0 {
+		return 30
+	}
+	return c.WarnSoonDays
+}
+
 func (c CertChecker) Run(ctx *Context) base.Result {
 	addr := fmt.Sprintf("%s:%d", c.Host, c.portOrDefault())
 	conn, err := tls.Dial("tcp", addr, &tls.Config{})
 	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) {
+			return networkErrorResult(err)
+		}
 		return base.FailResult(niceTlsError(err))
 	}
 	defer conn.Close()
@@ -70,25 +83,37 @@ func (c CertChecker) Run(ctx *Context) base.Result {
 		return base.FailResult(err.Error())
 	}
 
-	errorMsg := "No certificate found"
+	result := base.FailResult("No certificate found")
 	for _, cert := range conn.ConnectionState().PeerCertificates {
 		if !cert.IsCA {
-			ok, msg := c.verifyExpiry(cert, time.Now())
-			if ok {
-				return base.GoodResult()
+			result = c.verifyExpiry(cert, time.Now())
+			if result.Status != "fail" {
+				return result
 			}
-			errorMsg = msg
 		}
 	}
-	return base.FailResult(errorMsg)
+	return result
 }
 
-func (c CertChecker) verifyExpiry(crt *x509.Certificate, now time.Time) (bool, string) {
+// Message of cert results about a cert that expires soon
+const certExpiresFormat = "Cert expires in %d days"
+
+// IsCertExpiresMsg returns true if a cert check result message is about a cert that expires soon
+func IsCertExpiresMsg(msg string) bool {
+	var days int
+	_, err := fmt.Sscanf(msg, certExpiresFormat, &days)
+	return err == nil
+}
+
+func (c CertChecker) verifyExpiry(crt *x509.Certificate, now time.Time) base.Result {
 	toExpiry := crt.NotAfter.Sub(now)
 	if toExpiry < time.Duration(c.expiresSoonDaysOrDefault()*24)*time.Hour {
-		return false, fmt.Sprintf("Cert expires in %d days", int(toExpiry.Hours()/24))
+		return base.FailResult(fmt.Sprintf(certExpiresFormat, int(toExpiry.Hours()/24)))
+	}
+	if toExpiry < time.Duration(c.warnSoonDaysOrDefault()*24)*time.Hour {
+		return base.WarningResult(fmt.Sprintf(certExpiresFormat, int(toExpiry.Hours()/24)))
 	}
-	return true, ""
+	return base.GoodResult()
 }
 
 func niceTlsError(err error) string {
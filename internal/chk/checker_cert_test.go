@@ -0,0 +1,35 @@
+package chk
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+)
+
+func TestCertVerifyExpiry(t *testing.T) {
+	now := time.Now()
+	c := CertChecker{Host: "example.com"}
+
+	cases := []struct {
+		days   int
+		status string
+	}{
+		{60, "good"},
+		{25, "warning"},
+		{10, "fail"},
+		{-1, "fail"},
+	}
+	for _, tc := range cases {
+		crt := &x509.Certificate{NotAfter: now.Add(time.Duration(tc.days*24+1) * time.Hour)}
+		res := c.verifyExpiry(crt, now)
+		if res.Status != tc.status {
+			t.Errorf("Expected %s for cert expiring in %d days, got %s", tc.status, tc.days, res.Status)
+		}
+		if res.Status != "good" && !IsCertExpiresMsg(res.Msg) {
+			t.Errorf("Expected %q to be about cert expiry", res.Msg)
+		}
+	}
+	if IsCertExpiresMsg("Cert expired or not yet valid") {
+		t.Error("Expected expired cert not to expire soon")
+	}
+}
@@ -0,0 +1,152 @@
+package chk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rymdhund/whazza/internal/base"
+)
+
+type DiskFreeChecker struct {
+	Mountpoint string `json:"mountpoint"`
+	Free       string `json:"free,omitempty"`
+	FreeInodes string `json:"free_inodes,omitempty"`
+}
+
+type diskStats struct {
+	totalBytes  uint64
+	freeBytes   uint64
+	totalInodes uint64
+	freeInodes  uint64
+}
+
+// threshold is a limit given either as a percentage ("30%") or as an absolute amount ("10G", "5000")
+type threshold struct {
+	percent   float64
+	absolute  uint64
+	isPercent bool
+}
+
+func (c DiskFreeChecker) Title() string {
+	return fmt.Sprintf("disk-free:%s", c.Mountpoint)
+}
+
+func (c DiskFreeChecker) Type() string {
+	return "disk-free"
+}
+
+func (c DiskFreeChecker) Validate() error {
+	if c.Mountpoint == "" {
+		return errors.New("Empty mountpoint in disk-free check")
+	}
+	if free := c.freeOrDefault(); free != "" {
+		if _, err := parseThreshold(free, 1024); err != nil {
+			return fmt.Errorf("Invalid free limit in disk-free check: %w", err)
+		}
+	}
+	if c.FreeInodes != "" {
+		if _, err := parseThreshold(c.FreeInodes, 1000); err != nil {
+			return fmt.Errorf("Invalid free_inodes limit in disk-free check: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c DiskFreeChecker) AsJson() []byte {
+	b, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func (c DiskFreeChecker) freeOrDefault() string {
+	if c.Free == "" && c.FreeInodes == "" {
+		return "10%"
+	}
+	return c.Free
+}
+
+func (c DiskFreeChecker) Run(ctx *Context) base.Result {
+	stats, err := statDisk(c.Mountpoint)
+	if err != nil {
+		return base.ErrorResult(fmt.Sprintf("Couldn't stat %s: %s", c.Mountpoint, err))
+	}
+	return c.verify(stats)
+}
+
+func (c DiskFreeChecker) verify(stats diskStats) base.Result {
+	if free := c.freeOrDefault(); free != "" {
+		limit, _ := parseThreshold(free, 1024)
+		if !limit.satisfied(stats.freeBytes, stats.totalBytes) {
+			return base.FailResult(fmt.Sprintf("Only %s (%.1f%%) free on %s, limit is %s",
+				humanBytes(stats.freeBytes), percentOf(stats.freeBytes, stats.totalBytes), c.Mountpoint, free))
+		}
+	}
+	if c.FreeInodes != "" {
+		limit, _ := parseThreshold(c.FreeInodes, 1000)
+		if !limit.satisfied(stats.freeInodes, stats.totalInodes) {
+			return base.FailResult(fmt.Sprintf("Only %d (%.1f%%) inodes free on %s, limit is %s",
+				stats.freeInodes, percentOf(stats.freeInodes, stats.totalInodes), c.Mountpoint, c.FreeInodes))
+		}
+	}
+	return base.GoodResult()
+}
+
+// parseThreshold parses "30%", "512", "10k", "2.5G" etc. Suffixes k, M, G, T multiply with powers of unit.
+func parseThreshold(s string, unit float64) (threshold, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "%") {
+		p, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil || p < 0 || p > 100 {
+			return threshold{}, fmt.Errorf("Invalid percentage: %q", s)
+		}
+		return threshold{percent: p, isPercent: true}, nil
+	}
+
+	multiplier := 1.0
+	suffixes := "KMGT"
+	if len(s) > 0 {
+		if i := strings.IndexByte(suffixes, strings.ToUpper(s[len(s)-1:])[0]); i >= 0 {
+			for j := 0; j <= i; j++ {
+				multiplier *= unit
+			}
+			s = s[:len(s)-1]
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return threshold{}, fmt.Errorf("Invalid size: %q", s)
+	}
+	return threshold{absolute: uint64(v * multiplier)}, nil
+}
+
+func (t threshold) satisfied(free, total uint64) bool {
+	if t.isPercent {
+		return percentOf(free, total) >= t.percent
+	}
+	return free >= t.absolute
+}
+
+func percentOf(part, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
+
+func humanBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%dB", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd
+
+package chk
+
+import "errors"
+
+func statDisk(mountpoint string) (diskStats, error) {
+	return diskStats{}, errors.New("disk-free checks are not supported on this platform")
+}
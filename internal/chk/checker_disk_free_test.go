@@ -0,0 +1,101 @@
+package chk
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseThreshold(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected threshold
+	}{
+		{"30%", threshold{percent: 30, isPercent: true}},
+		{"512", threshold{absolute: 512}},
+		{"10k", threshold{absolute: 10 * 1024}},
+		{"2G", threshold{absolute: 2 * 1024 * 1024 * 1024}},
+		{"1.5M", threshold{absolute: 1536 * 1024}},
+	}
+	for _, c := range cases {
+		th, err := parseThreshold(c.input, 1024)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %s", c.input, err)
+		}
+		if th != c.expected {
+			t.Errorf("Wrong threshold for %s: %+v", c.input, th)
+		}
+	}
+
+	for _, input := range []string{"", "abc", "120%", "-1", "G", "10X"} {
+		if _, err := parseThreshold(input, 1024); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
+
+func TestDiskFreeVerify(t *testing.T) {
+	stats := diskStats{
+		totalBytes:  100 * 1024 * 1024 * 1024,
+		freeBytes:   20 * 1024 * 1024 * 1024,
+		totalInodes: 1000000,
+		freeInodes:  10000,
+	}
+
+	res := DiskFreeChecker{Mountpoint: "/var", Free: "10%"}.verify(stats)
+	if res.Status != "good" {
+		t.Fatalf("Expected good, got %s: %s", res.Status, res.Msg)
+	}
+
+	res = DiskFreeChecker{Mountpoint: "/var", Free: "30%"}.verify(stats)
+	if res.Status != "fail" {
+		t.Fatalf("Expected fail, got %s", res.Status)
+	}
+	if !strings.Contains(res.Msg, "20.0G (20.0%) free on /var") {
+		t.Fatalf("Unexpected message: %s", res.Msg)
+	}
+
+	res = DiskFreeChecker{Mountpoint: "/var", Free: "25G"}.verify(stats)
+	if res.Status != "fail" {
+		t.Fatalf("Expected fail, got %s", res.Status)
+	}
+
+	res = DiskFreeChecker{Mountpoint: "/var", FreeInodes: "5%"}.verify(stats)
+	if res.Status != "fail" {
+		t.Fatalf("Expected fail, got %s", res.Status)
+	}
+
+	res = DiskFreeChecker{Mountpoint: "/var", FreeInodes: "5k"}.verify(stats)
+	if res.Status != "good" {
+		t.Fatalf("Expected good, got %s: %s", res.Status, res.Msg)
+	}
+}
+
+func TestDiskFreeRun(t *testing.T) {
+	res := DiskFreeChecker{Mountpoint: "/", Free: "0%"}.Run(NewContext())
+	if res.Status != "good" {
+		t.Fatalf("Expected good, got %s: %s", res.Status, res.Msg)
+	}
+
+	res = DiskFreeChecker{Mountpoint: "/does/not/exist"}.Run(NewContext())
+	if res.Status != "error" {
+		t.Fatalf("Expected error, got %s", res.Status)
+	}
+}
+
+func TestDiskFreeUnmarshal(t *testing.T) {
+	var check Check
+	input := `{"type": "disk-free", "interval": 60, "mountpoint": "/", "free_inodes": "5%"}`
+	if err := json.Unmarshal([]byte(input), &check); err != nil {
+		t.Fatalf("Unexpected error for inode only check: %s", err)
+	}
+	checker, ok := check.Checker.(DiskFreeChecker)
+	if !ok || checker.FreeInodes != "5%" || checker.freeOrDefault() != "" {
+		t.Fatalf("Unexpected checker %+v", check.Checker)
+	}
+
+	input = `{"type": "disk-free", "interval": 60, "mountpoint": "/", "free": "lots", "free_inodes": "5%"}`
+	if err := json.Unmarshal([]byte(input), &check); err == nil {
+		t.Fatal("Expected error for invalid free limit")
+	}
+}
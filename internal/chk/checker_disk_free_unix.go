@@ -0,0 +1,20 @@
+//go:build linux || darwin || freebsd
+
+package chk
+
+import "syscall"
+
+func statDisk(mountpoint string) (diskStats, error) {
+	var st syscall.Statfs_t
+	err := syscall.Statfs(mountpoint, &st)
+	if err != nil {
+		return diskStats{}, err
+	}
+	bsize := uint64(st.Bsize)
+	return diskStats{
+		totalBytes:  uint64(st.Blocks) * bsize,
+		freeBytes:   uint64(st.Bavail) * bsize,
+		totalInodes: uint64(st.Files),
+		freeInodes:  uint64(st.Ffree),
+	}, nil
+}
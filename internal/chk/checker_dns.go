@@ -0,0 +1,204 @@
+package chk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/base"
+)
+
+type DnsChecker struct {
+	Name       string   `json:"name"`
+	RecordType string   `json:"record_type,omitempty"`
+	Resolver   string   `json:"resolver,omitempty"`
+	Expected   []string `json:"expected,omitempty"`
+	Timeout    int      `json:"timeout,omitempty"`
+}
+
+var dnsRecordTypes = []string{"A", "AAAA", "CNAME", "MX", "TXT", "NS"}
+
+func (c DnsChecker) Title() string {
+	if c.Resolver != "" {
+		return fmt.Sprintf("dns:%s:%s@%s", c.Name, c.recordTypeOrDefault(), c.Resolver)
+	}
+	return fmt.Sprintf("dns:%s:%s", c.Name, c.recordTypeOrDefault())
+}
+
+func (c DnsChecker) Type() string {
+	return "dns"
+}
+
+func (c DnsChecker) Validate() error {
+	if c.Name == "" {
+		return errors.New("Empty name in dns check")
+	}
+	valid := false
+	for _, t := range dnsRecordTypes {
+		if c.recordTypeOrDefault() == t {
+			valid = true
+		}
+	}
+	if !valid {
+		return fmt.Errorf("Invalid record type in dns check: %s", c.RecordType)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("Invalid timeout in dns check: %d", c.Timeout)
+	}
+	return nil
+}
+
+func (c DnsChecker) AsJson() []byte {
+	b, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func (c DnsChecker) recordTypeOrDefault() string {
+	if c.RecordType == "" {
+		return "A"
+	}
+	return strings.ToUpper(c.RecordType)
+}
+
+func (c DnsChecker) timeoutOrDefault() time.Duration {
+	if c.Timeout == 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(c.Timeout) * time.Second
+}
+
+// resolverAddr returns the configured resolver as host:port, defaulting to port 53
+func (c DnsChecker) resolverAddr() string {
+	if _, _, err := net.SplitHostPort(c.Resolver); err == nil {
+		return c.Resolver
+	}
+	return net.JoinHostPort(c.Resolver, "53")
+}
+
+func (c DnsChecker) resolver() *net.Resolver {
+	if c.Resolver == "" {
+		return net.DefaultResolver
+	}
+	addr := c.resolverAddr()
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+func (c DnsChecker) Run(ctx *Context) base.Result {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), c.timeoutOrDefault())
+	defer cancel()
+
+	answers, err := c.lookup(timeoutCtx)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return base.FailResult(fmt.Sprintf("No %s records for %s", c.recordTypeOrDefault(), c.Name))
+		}
+		return networkErrorResult(err)
+	}
+	return c.verify(answers)
+}
+
+func (c DnsChecker) verify(answers []string) base.Result {
+	got := c.normalize(answers)
+	if len(got) == 0 {
+		return base.FailResult(fmt.Sprintf("No %s records for %s", c.recordTypeOrDefault(), c.Name))
+	}
+	if c.Expected != nil {
+		expected := c.normalize(c.Expected)
+		if strings.Join(got, " ") != strings.Join(expected, " ") {
+			return base.FailResult(fmt.Sprintf("Unexpected %s records for %s: got [%s], expected [%s]",
+				c.recordTypeOrDefault(), c.Name, strings.Join(got, ", "), strings.Join(expected, ", ")))
+		}
+	}
+	return base.GoodResult()
+}
+
+// lookup returns the answers for the record type. MX answers are the host names without preference.
+func (c DnsChecker) lookup(ctx context.Context) ([]string, error) {
+	r := c.resolver()
+	answers := []string{}
+
+	switch c.recordTypeOrDefault() {
+	case "A", "AAAA":
+		network := "ip4"
+		if c.recordTypeOrDefault() == "AAAA" {
+			network = "ip6"
+		}
+		ips, err := r.LookupIP(ctx, network, c.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			answers = append(answers, ip.String())
+		}
+	case "CNAME":
+		cname, err := r.LookupCNAME(ctx, c.Name)
+		if err != nil {
+			return nil, err
+		}
+		// Names without a CNAME record resolve to themselves
+		if !strings.EqualFold(strings.TrimSuffix(cname, "."), strings.TrimSuffix(c.Name, ".")) {
+			answers = append(answers, cname)
+		}
+	case "MX":
+		mxs, err := r.LookupMX(ctx, c.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range mxs {
+			answers = append(answers, mx.Host)
+		}
+	case "TXT":
+		txts, err := r.LookupTXT(ctx, c.Name)
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, txts...)
+	case "NS":
+		nss, err := r.LookupNS(ctx, c.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range nss {
+			answers = append(answers, ns.Host)
+		}
+	}
+	return answers, nil
+}
+
+// normalize makes answers comparable: names are lowercased without trailing dot, ips are in canonical form.
+// The result is sorted and without duplicates.
+func (c DnsChecker) normalize(answers []string) []string {
+	set := map[string]bool{}
+	for _, a := range answers {
+		switch c.recordTypeOrDefault() {
+		case "A", "AAAA":
+			if ip := net.ParseIP(a); ip != nil {
+				a = ip.String()
+			}
+		case "CNAME", "MX", "NS":
+			a = strings.TrimSuffix(strings.ToLower(a), ".")
+		}
+		set[a] = true
+	}
+	normalized := make([]string, 0, len(set))
+	for a := range set {
+		normalized = append(normalized, a)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
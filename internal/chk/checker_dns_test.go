@@ -0,0 +1,127 @@
+package chk
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// startDnsServer starts a minimal dns server answering every A query with the given ip
+func startDnsServer(t *testing.T, ip net.IP) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := buf[:n]
+			// Find the end of the question section
+			i := 12
+			for i < n && req[i] != 0 {
+				i += int(req[i]) + 1
+			}
+			qEnd := i + 5
+			if qEnd > n {
+				continue
+			}
+			qtype := binary.BigEndian.Uint16(req[i+1:])
+
+			resp := make([]byte, qEnd)
+			copy(resp, req[:qEnd])
+			resp[2] = 0x81 // response, recursion desired
+			resp[3] = 0x80 // recursion available
+			binary.BigEndian.PutUint16(resp[8:], 0)
+			binary.BigEndian.PutUint16(resp[10:], 0)
+			if qtype == 1 {
+				binary.BigEndian.PutUint16(resp[6:], 1)
+				resp = append(resp, 0xc0, 0x0c, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+				resp = append(resp, ip.To4()...)
+			} else {
+				binary.BigEndian.PutUint16(resp[6:], 0)
+			}
+			conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestDnsLookup(t *testing.T) {
+	resolver := startDnsServer(t, net.ParseIP("192.0.2.10"))
+
+	res := DnsChecker{Name: "www.example.com", Resolver: resolver, Timeout: 2}.Run(NewContext())
+	if res.Status != "good" {
+		t.Fatalf("Expected good, got %s: %s", res.Status, res.Msg)
+	}
+
+	res = DnsChecker{Name: "www.example.com", Resolver: resolver, Expected: []string{"192.0.2.10"}, Timeout: 2}.Run(NewContext())
+	if res.Status != "good" {
+		t.Fatalf("Expected good, got %s: %s", res.Status, res.Msg)
+	}
+
+	res = DnsChecker{Name: "www.example.com", Resolver: resolver, Expected: []string{"192.0.2.11"}, Timeout: 2}.Run(NewContext())
+	if res.Status != "fail" {
+		t.Fatalf("Expected fail, got %s", res.Status)
+	}
+
+	res = DnsChecker{Name: "www.example.com", RecordType: "AAAA", Resolver: resolver, Timeout: 2}.Run(NewContext())
+	if res.Status != "fail" {
+		t.Fatalf("Expected fail, got %s", res.Status)
+	}
+
+	// The server has no CNAME records
+	res = DnsChecker{Name: "www.example.com", RecordType: "CNAME", Resolver: resolver, Expected: []string{"www.example.com"}, Timeout: 2}.Run(NewContext())
+	if res.Status != "fail" {
+		t.Fatalf("Expected fail for missing CNAME, got %s: %s", res.Status, res.Msg)
+	}
+}
+
+func TestDnsTimeout(t *testing.T) {
+	// A resolver that never answers
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	res := DnsChecker{Name: "www.example.com", Resolver: conn.LocalAddr().String(), Timeout: 1}.Run(NewContext())
+	if res.Status != "error" {
+		t.Fatalf("Expected error, got %s: %s", res.Status, res.Msg)
+	}
+}
+
+func TestDnsVerify(t *testing.T) {
+	c := DnsChecker{Name: "example.com", RecordType: "mx", Expected: []string{"MX2.example.com.", "mx1.example.com"}}
+	res := c.verify([]string{"mx1.example.com.", "mx2.example.com."})
+	if res.Status != "good" {
+		t.Fatalf("Expected good, got %s: %s", res.Status, res.Msg)
+	}
+
+	res = c.verify([]string{"mx1.example.com."})
+	if res.Status != "fail" {
+		t.Fatalf("Expected fail, got %s", res.Status)
+	}
+
+	res = DnsChecker{Name: "example.com"}.verify([]string{})
+	if res.Status != "fail" {
+		t.Fatalf("Expected fail, got %s", res.Status)
+	}
+}
+
+func TestDnsValidate(t *testing.T) {
+	if err := (DnsChecker{Name: "example.com", RecordType: "txt"}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := (DnsChecker{Name: "example.com", RecordType: "SOA"}).Validate(); err == nil {
+		t.Fatal("Expected error")
+	}
+	if err := (DnsChecker{}).Validate(); err == nil {
+		t.Fatal("Expected error")
+	}
+}
@@ -0,0 +1,252 @@
+package chk
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/base"
+)
+
+type DomainExpiryChecker struct {
+	Domain          string `json:"domain"`
+	ExpiresSoonDays int    `json:"expires_soon_days,omitempty"`
+	WarnSoonDays    int    `json:"warn_soon_days,omitempty"`
+	RdapServer      string `json:"rdap_server,omitempty"`
+	WhoisServer     string `json:"whois_server,omitempty"`
+	Timeout         int    `json:"timeout,omitempty"`
+}
+
+const ianaWhoisServer = "whois.iana.org:43"
+
+// Keys used by different registries for the expiry date in whois responses
+var whoisExpiryKeys = []string{
+	"registry expiry date",
+	"registrar registration expiration date",
+	"expiration date",
+	"expiry date",
+	"expire date",
+	"expires on",
+	"expires",
+	"paid-till",
+	"renewal date",
+}
+
+var whoisDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02",
+	"2006.01.02",
+	"2006/01/02",
+	"02-Jan-2006",
+	"02.01.2006",
+}
+
+func (c DomainExpiryChecker) Title() string {
+	return fmt.Sprintf("domain:%s", c.Domain)
+}
+
+func (c DomainExpiryChecker) Type() string {
+	return "domain-expiry"
+}
+
+func (c DomainExpiryChecker) Validate() error {
+	if c.Domain == "" {
+		return errors.New("Empty domain in domain-expiry check")
+	}
+	if c.RdapServer != "" {
+		if _, err := url.Parse(c.RdapServer); err != nil {
+			return fmt.Errorf("Invalid rdap_server in domain-expiry check: %w", err)
+		}
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("Invalid timeout in domain-expiry check: %d", c.Timeout)
+	}
+	return nil
+}
+
+func (c DomainExpiryChecker) AsJson() []byte {
+	b, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func (c DomainExpiryChecker) expiresSoonDaysOrDefault() int {
+	if c.ExpiresSoonDays == 0 {
+		return 30
+	}
+	return c.ExpiresSoonDays
+}
+
+func (c DomainExpiryChecker) warnSoonDaysOrDefault() int {
+	if c.WarnSoonDays == 0 {
+		return 60
+	}
+	return c.WarnSoonDays
+}
+
+func (c DomainExpiryChecker) rdapServerOrDefault() string {
+	if c.RdapServer == "" {
+		return "https://rdap.org"
+	}
+	return strings.TrimSuffix(c.RdapServer, "/")
+}
+
+func (c DomainExpiryChecker) timeoutOrDefault() time.Duration {
+	if c.Timeout == 0 {
+		return 20 * time.Second
+	}
+	return time.Duration(c.Timeout) * time.Second
+}
+
+func (c DomainExpiryChecker) Run(ctx *Context) base.Result {
+	expiry, rdapErr := c.rdapExpiry()
+	if rdapErr != nil {
+		var whoisErr error
+		expiry, whoisErr = c.whoisExpiry()
+		if whoisErr != nil {
+			return base.ErrorResult(fmt.Sprintf("Couldn't find expiry date. rdap: %s, whois: %s", rdapErr, whoisErr))
+		}
+	}
+	return c.verifyExpiry(expiry, time.Now())
+}
+
+func (c DomainExpiryChecker) verifyExpiry(expiry time.Time, now time.Time) base.Result {
+	toExpiry := expiry.Sub(now)
+	if toExpiry < time.Duration(c.expiresSoonDaysOrDefault()*24)*time.Hour {
+		return base.FailResult(fmt.Sprintf("Domain expires in %d days", int(toExpiry.Hours()/24)))
+	}
+	if toExpiry < time.Duration(c.warnSoonDaysOrDefault()*24)*time.Hour {
+		return base.WarningResult(fmt.Sprintf("Domain expires in %d days", int(toExpiry.Hours()/24)))
+	}
+	return base.GoodResult()
+}
+
+func (c DomainExpiryChecker) rdapExpiry() (time.Time, error) {
+	type rdapResponse struct {
+		Events []struct {
+			EventAction string `json:"eventAction"`
+			EventDate   string `json:"eventDate"`
+		} `json:"events"`
+	}
+
+	client := &http.Client{Timeout: c.timeoutOrDefault()}
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/domain/%s", c.rdapServerOrDefault(), url.PathEscape(c.Domain)), nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("Unexpected status: %d", resp.StatusCode)
+	}
+
+	var data rdapResponse
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1024*1024)).Decode(&data)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, event := range data.Events {
+		if event.EventAction == "expiration" {
+			return time.Parse(time.RFC3339, event.EventDate)
+		}
+	}
+	return time.Time{}, errors.New("No expiration event")
+}
+
+// whoisExpiry queries the configured whois server, or follows the referral from IANA if none is configured
+func (c DomainExpiryChecker) whoisExpiry() (time.Time, error) {
+	server := c.WhoisServer
+	if server == "" {
+		resp, err := c.whoisQuery(ianaWhoisServer)
+		if err != nil {
+			return time.Time{}, err
+		}
+		refer := whoisValue(resp, []string{"refer", "whois"})
+		if refer == "" {
+			return time.Time{}, errors.New("No whois server found for domain")
+		}
+		server = refer
+	}
+
+	resp, err := c.whoisQuery(server)
+	if err != nil {
+		return time.Time{}, err
+	}
+	value := whoisValue(resp, whoisExpiryKeys)
+	if value == "" {
+		return time.Time{}, errors.New("No expiry date in whois response")
+	}
+	return parseWhoisDate(value)
+}
+
+func (c DomainExpiryChecker) whoisQuery(server string) (string, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "43")
+	}
+	conn, err := net.DialTimeout("tcp", server, c.timeoutOrDefault())
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.timeoutOrDefault()))
+
+	_, err = fmt.Fprintf(conn, "%s\r\n", c.Domain)
+	if err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(io.LimitReader(conn, 1024*1024))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// whoisValue returns the value of the first line with one of the given keys. Keys are matched case insensitively.
+func whoisValue(resp string, keys []string) string {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(resp))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if _, exists := values[key]; !exists && value != "" {
+			values[key] = value
+		}
+	}
+	for _, k := range keys {
+		if v, ok := values[k]; ok {
+			return v
+		}
+	}
+	return ""
+}
+
+func parseWhoisDate(value string) (time.Time, error) {
+	for _, layout := range whoisDateLayouts {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Unknown date format: %q", value)
+}
@@ -0,0 +1,86 @@
+package chk
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func startWhoisServer(t *testing.T, response string) string {
+	host, port := startTcpServer(t, func(conn net.Conn) {
+		_, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			return
+		}
+		conn.Write([]byte(response))
+	})
+	return fmt.Sprintf("%s:%d", host, port)
+}
+
+func TestDomainExpiryRdap(t *testing.T) {
+	expiry := time.Now().Add(100 * 24 * time.Hour).UTC().Format(time.RFC3339)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/domain/example.com" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"events":[{"eventAction":"registration","eventDate":"2000-01-01T00:00:00Z"},{"eventAction":"expiration","eventDate":"%s"}]}`, expiry)
+	}))
+	defer server.Close()
+
+	res := DomainExpiryChecker{Domain: "example.com", RdapServer: server.URL}.Run(NewContext())
+	if res.Status != "good" {
+		t.Fatalf("Expected good, got %s: %s", res.Status, res.Msg)
+	}
+
+	res = DomainExpiryChecker{Domain: "example.com", RdapServer: server.URL, WarnSoonDays: 120}.Run(NewContext())
+	if res.Status != "warning" {
+		t.Fatalf("Expected warning, got %s", res.Status)
+	}
+
+	res = DomainExpiryChecker{Domain: "example.com", RdapServer: server.URL, ExpiresSoonDays: 120}.Run(NewContext())
+	if res.Status != "fail" {
+		t.Fatalf("Expected fail, got %s", res.Status)
+	}
+}
+
+func TestDomainExpiryWhoisFallback(t *testing.T) {
+	rdap := httptest.NewServer(http.NotFoundHandler())
+	defer rdap.Close()
+
+	expiry := time.Now().Add(10 * 24 * time.Hour).UTC().Format("2006-01-02T15:04:05Z")
+	whois := startWhoisServer(t, fmt.Sprintf("Domain Name: EXAMPLE.COM\r\nRegistry Expiry Date: %s\r\n", expiry))
+
+	res := DomainExpiryChecker{Domain: "example.com", RdapServer: rdap.URL, WhoisServer: whois}.Run(NewContext())
+	if res.Status != "fail" {
+		t.Fatalf("Expected fail, got %s: %s", res.Status, res.Msg)
+	}
+	if res.Msg != "Domain expires in 9 days" {
+		t.Fatalf("Unexpected message: %s", res.Msg)
+	}
+
+	whois = startWhoisServer(t, "No match for domain\r\n")
+	res = DomainExpiryChecker{Domain: "example.com", RdapServer: rdap.URL, WhoisServer: whois}.Run(NewContext())
+	if res.Status != "error" {
+		t.Fatalf("Expected error, got %s", res.Status)
+	}
+}
+
+func TestParseWhoisDate(t *testing.T) {
+	for _, input := range []string{"2030-05-01T00:00:00Z", "2030-05-01", "01-May-2030", "2030.05.01", "2030-05-01T00:00:00+0000"} {
+		d, err := parseWhoisDate(input)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %s", input, err)
+		}
+		if d.Year() != 2030 || d.Month() != time.May || d.Day() != 1 {
+			t.Errorf("Wrong date for %s: %s", input, d)
+		}
+	}
+	if _, err := parseWhoisDate("soon"); err == nil {
+		t.Error("Expected error")
+	}
+}
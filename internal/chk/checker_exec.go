@@ -0,0 +1,193 @@
+package chk
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/base"
+)
+
+// ExecChecker runs a local command and interprets the exit code like a Nagios plugin
+type ExecChecker struct {
+	Command string            `json:"command"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	Timeout int               `json:"timeout,omitempty"`
+}
+
+// Nagios plugin exit codes
+const (
+	nagiosOk       = 0
+	nagiosWarning  = 1
+	nagiosCritical = 2
+	nagiosUnknown  = 3
+)
+
+func (c ExecChecker) Title() string {
+	parts := append([]string{filepath.Base(c.Command)}, c.Args...)
+	return fmt.Sprintf("exec:%s", strings.Join(parts, " "))
+}
+
+func (c ExecChecker) Type() string {
+	return "exec"
+}
+
+func (c ExecChecker) Validate() error {
+	if c.Command == "" {
+		return errors.New("Empty command in exec check")
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("Invalid timeout in exec check: %d", c.Timeout)
+	}
+	return nil
+}
+
+func (c ExecChecker) AsJson() []byte {
+	b, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func (c ExecChecker) timeoutOrDefault() time.Duration {
+	if c.Timeout == 0 {
+		return 60 * time.Second
+	}
+	return time.Duration(c.Timeout) * time.Second
+}
+
+func (c ExecChecker) environ() []string {
+	env := os.Environ()
+	keys := make([]string, 0, len(c.Env))
+	for k := range c.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, fmt.Sprintf("%s=%s", k, c.Env[k]))
+	}
+	return env
+}
+
+func (c ExecChecker) Run(ctx *Context) base.Result {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), c.timeoutOrDefault())
+	defer cancel()
+
+	// We read stdout through our own pipe so that children of the command that keep stdout open can't block us
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		return base.ErrorResult(fmt.Sprintf("Couldn't run command: %s", err))
+	}
+	defer stdoutReader.Close()
+
+	cmd := exec.CommandContext(timeoutCtx, c.Command, c.Args...)
+	cmd.Env = c.environ()
+	cmd.Stdout = stdoutWriter
+
+	err = cmd.Start()
+	stdoutWriter.Close()
+	if err != nil {
+		return base.ErrorResult(fmt.Sprintf("Couldn't run command: %s", err))
+	}
+
+	// Keep the start of the output and drain the rest so that the command never blocks on writing
+	var stdout lockedBuffer
+	readDone := make(chan struct{})
+	go func() {
+		io.Copy(&stdout, io.LimitReader(stdoutReader, maxExecOutput))
+		io.Copy(io.Discard, stdoutReader)
+		close(readDone)
+	}()
+
+	err = cmd.Wait()
+	// Children of the command can keep stdout open after it exits
+	select {
+	case <-readDone:
+	case <-time.After(execOutputGrace):
+	}
+	if err != nil && timeoutCtx.Err() == context.DeadlineExceeded {
+		return base.FailResult(fmt.Sprintf("Timed out after %s", c.timeoutOrDefault()))
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return nagiosResult(nagiosOk, firstLine(stdout.bytes()))
+	case errors.As(err, &exitErr):
+		return nagiosResult(exitErr.ExitCode(), firstLine(stdout.bytes()))
+	default:
+		return base.ErrorResult(fmt.Sprintf("Couldn't run command: %s", err))
+	}
+}
+
+// How much output of a command we keep, and how long we wait for it after the command exited
+const (
+	maxExecOutput   = 64 * 1024
+	execOutputGrace = 500 * time.Millisecond
+)
+
+// lockedBuffer is a buffer that can be read while it is written
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+// nagiosResult maps a plugin exit code to a result. Unknown states are reported as errors.
+func nagiosResult(code int, msg string) base.Result {
+	switch code {
+	case nagiosOk:
+		res := base.GoodResult()
+		res.Msg = msg
+		return res
+	case nagiosWarning:
+		return base.WarningResult(msgOrDefault(msg, "WARNING"))
+	case nagiosCritical:
+		return base.FailResult(msgOrDefault(msg, "CRITICAL"))
+	case nagiosUnknown:
+		return base.ErrorResult(msgOrDefault(msg, "UNKNOWN"))
+	default:
+		return base.ErrorResult(msgOrDefault(msg, fmt.Sprintf("Unexpected exit status %d", code)))
+	}
+}
+
+func msgOrDefault(msg, def string) string {
+	if msg == "" {
+		return def
+	}
+	return msg
+}
+
+// firstLine returns the first line of plugin output without any performance data
+func firstLine(output []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	if !scanner.Scan() {
+		return ""
+	}
+	line, _, _ := strings.Cut(scanner.Text(), "|")
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,80 @@
+package chk
+
+import (
+	"testing"
+	"time"
+)
+
+func shCheck(script string) ExecChecker {
+	return ExecChecker{Command: "/bin/sh", Args: []string{"-c", script}, Timeout: 5}
+}
+
+func TestExecExitCodes(t *testing.T) {
+	cases := []struct {
+		script string
+		status string
+		msg    string
+	}{
+		{"echo 'DISK OK - free space: / 3326 MB (56%) | /=2643MB;5948'; exit 0", "good", "DISK OK - free space: / 3326 MB (56%)"},
+		{"echo 'DISK WARNING - free space: / 300 MB'; exit 1", "warning", "DISK WARNING - free space: / 300 MB"},
+		{"exit 1", "warning", "WARNING"},
+		{"echo 'DISK CRITICAL - free space: / 30 MB'; echo second line; exit 2", "fail", "DISK CRITICAL - free space: / 30 MB"},
+		{"exit 2", "fail", "CRITICAL"},
+		{"echo 'Invalid argument'; exit 3", "error", "Invalid argument"},
+		{"exit 7", "error", "Unexpected exit status 7"},
+	}
+	for _, c := range cases {
+		res := shCheck(c.script).Run(NewContext())
+		if res.Status != c.status {
+			t.Errorf("Expected %s for %q, got %s", c.status, c.script, res.Status)
+		}
+		if res.Msg != c.msg {
+			t.Errorf("Unexpected message for %q: %q", c.script, res.Msg)
+		}
+	}
+}
+
+func TestExecEnv(t *testing.T) {
+	c := shCheck(`test "$WHAZZA_TEST" = "hello"`)
+	c.Env = map[string]string{"WHAZZA_TEST": "hello"}
+	res := c.Run(NewContext())
+	if res.Status != "good" {
+		t.Fatalf("Expected good, got %s: %s", res.Status, res.Msg)
+	}
+}
+
+func TestExecTimeout(t *testing.T) {
+	c := shCheck("sleep 5")
+	c.Timeout = 1
+	res := c.Run(NewContext())
+	if res.Status != "fail" || res.Msg != "Timed out after 1s" {
+		t.Fatalf("Expected timeout, got %s: %s", res.Status, res.Msg)
+	}
+}
+
+func TestExecNotFound(t *testing.T) {
+	res := ExecChecker{Command: "/does/not/exist"}.Run(NewContext())
+	if res.Status != "error" {
+		t.Fatalf("Expected error, got %s", res.Status)
+	}
+}
+
+func TestExecLargeOutput(t *testing.T) {
+	res := shCheck("echo 'OK - lots of output'; head -c 300000 /dev/zero; exit 0").Run(NewContext())
+	if res.Status != "good" || res.Msg != "OK - lots of output" {
+		t.Fatalf("Expected good, got %s: %s", res.Status, res.Msg)
+	}
+}
+
+func TestExecBackgroundedChild(t *testing.T) {
+	c := shCheck("sleep 3 & echo 'OK - started'; exit 0")
+	c.Timeout = 2
+	start := time.Now()
+	res := c.Run(NewContext())
+	if res.Status != "good" || res.Msg != "OK - started" {
+		t.Fatalf("Expected good, got %s: %s", res.Status, res.Msg)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Expected not to wait for the child, took %s", d)
+	}
+}
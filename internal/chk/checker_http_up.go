@@ -1,12 +1,18 @@
 package chk
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/rymdhund/whazza/internal/base"
 )
@@ -15,17 +21,33 @@ type HttpUpChecker struct {
 	Host        string `json:"host"`
 	Port        int    `json:"port,omitempty"`
 	StatusCodes []int  `json:"status_codes,omitempty"`
+	// Request settings
+	Path            string            `json:"path,omitempty"`
+	Method          string            `json:"method,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	Body            string            `json:"body,omitempty"`
+	FollowRedirects bool              `json:"follow_redirects,omitempty"`
+	// Response assertions
+	BodyContains    string      `json:"body_contains,omitempty"`
+	BodyRegex       string      `json:"body_regex,omitempty"`
+	JsonPath        string      `json:"json_path,omitempty"`
+	JsonValue       interface{} `json:"json_value,omitempty"`
+	MaxResponseTime int         `json:"max_response_time_ms,omitempty"`
 }
 
 type HttpsUpChecker struct {
 	HttpUpChecker
 }
 
+const maxHttpBodySize = 1024 * 1024
+
+var validMethod = regexp.MustCompile(`^[A-Z]+$`)
+
 func (c HttpUpChecker) Title() string {
 	if c.PortOrDefault() != 80 {
-		return fmt.Sprintf("http:%s:%d", c.Host, c.PortOrDefault())
+		return fmt.Sprintf("http:%s:%d%s", c.Host, c.PortOrDefault(), c.titlePath())
 	}
-	return fmt.Sprintf("http:%s", c.Host)
+	return fmt.Sprintf("http:%s%s", c.Host, c.titlePath())
 }
 
 func (c HttpUpChecker) Type() string {
@@ -36,6 +58,18 @@ func (c HttpUpChecker) Validate() error {
 	if c.Host == "" {
 		return errors.New("Empty host in http-up check")
 	}
+	if c.Path != "" && !strings.HasPrefix(c.Path, "/") {
+		return fmt.Errorf("Path must start with / in http-up check: %s", c.Path)
+	}
+	if !validMethod.MatchString(c.methodOrDefault()) {
+		return fmt.Errorf("Invalid method in http-up check: %s", c.Method)
+	}
+	if _, err := regexp.Compile(c.BodyRegex); err != nil {
+		return fmt.Errorf("Invalid body_regex in http-up check: %w", err)
+	}
+	if c.MaxResponseTime < 0 {
+		return fmt.Errorf("Invalid max_response_time_ms in http-up check: %d", c.MaxResponseTime)
+	}
 	return nil
 }
 
@@ -54,8 +88,29 @@ func (c HttpUpChecker) PortOrDefault() int {
 	return c.Port
 }
 
+func (c HttpUpChecker) pathOrDefault() string {
+	if c.Path == "" {
+		return "/"
+	}
+	return c.Path
+}
+
+func (c HttpUpChecker) titlePath() string {
+	if c.pathOrDefault() == "/" {
+		return ""
+	}
+	return c.Path
+}
+
+func (c HttpUpChecker) methodOrDefault() string {
+	if c.Method == "" {
+		return "GET"
+	}
+	return strings.ToUpper(c.Method)
+}
+
 func (c HttpUpChecker) Run(ctx *Context) base.Result {
-	return httpCheck(ctx.InsecureHttpTransport, c.Host, c.PortOrDefault(), c.StatusCodes, false)
+	return httpCheck(ctx.InsecureHttpTransport, c, c.PortOrDefault(), false)
 }
 
 ///////////////////
@@ -68,9 +123,9 @@ func (c HttpsUpChecker) Type() string {
 
 func (c HttpsUpChecker) Title() string {
 	if c.PortOrDefault() != 443 {
-		return fmt.Sprintf("https:%s:%d", c.Host, c.PortOrDefault())
+		return fmt.Sprintf("https:%s:%d%s", c.Host, c.PortOrDefault(), c.titlePath())
 	}
-	return fmt.Sprintf("https:%s", c.Host)
+	return fmt.Sprintf("https:%s%s", c.Host, c.titlePath())
 }
 
 func (c HttpsUpChecker) PortOrDefault() int {
@@ -81,42 +136,62 @@ func (c HttpsUpChecker) PortOrDefault() int {
 }
 
 func (c HttpsUpChecker) Run(ctx *Context) base.Result {
-	return httpCheck(ctx.InsecureHttpTransport, c.Host, c.PortOrDefault(), c.StatusCodes, true)
+	return httpCheck(ctx.InsecureHttpTransport, c.HttpUpChecker, c.PortOrDefault(), true)
 }
 
-func httpCheck(transp *http.Transport, host string, port int, statusCodes []int, https bool) base.Result {
-	// Dont follow redirects and allow bad certs
+func httpCheck(transp *http.Transport, c HttpUpChecker, port int, https bool) base.Result {
+	// Allow bad certs and only follow redirects if asked to
 	client := &http.Client{
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
 		Transport: transp,
 	}
+	if !c.FollowRedirects {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
 	var url string
 	if https {
 		if port == 443 {
-			url = fmt.Sprintf("https://%s/", host)
+			url = fmt.Sprintf("https://%s%s", c.Host, c.pathOrDefault())
 		} else {
-			url = fmt.Sprintf("https://%s:%d/", host, port)
+			url = fmt.Sprintf("https://%s:%d%s", c.Host, port, c.pathOrDefault())
 		}
 	} else {
 		if port == 80 {
-			url = fmt.Sprintf("http://%s/", host)
+			url = fmt.Sprintf("http://%s%s", c.Host, c.pathOrDefault())
 		} else {
-			url = fmt.Sprintf("http://%s:%d/", host, port)
+			url = fmt.Sprintf("http://%s:%d%s", c.Host, port, c.pathOrDefault())
 		}
 	}
-	resp, err := client.Get(url)
+	req, err := http.NewRequest(c.methodOrDefault(), url, strings.NewReader(c.Body))
 	if err != nil {
 		return base.FailResult(err.Error())
 	}
+	for k, v := range c.Headers {
+		if strings.EqualFold(k, "host") {
+			req.Host = v
+		} else {
+			req.Header.Set(k, v)
+		}
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return networkErrorResult(err)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxHttpBodySize))
 	io.Copy(ioutil.Discard, resp.Body)
 	resp.Body.Close()
+	elapsed := time.Since(start)
+	if err != nil {
+		return base.FailResult(fmt.Sprintf("Couldn't read body: %s", err))
+	}
 
-	if statusCodes != nil {
+	if c.StatusCodes != nil {
 		contains := false
-		for _, c := range statusCodes {
-			if resp.StatusCode == c {
+		for _, code := range c.StatusCodes {
+			if resp.StatusCode == code {
 				contains = true
 			}
 		}
@@ -129,5 +204,83 @@ func httpCheck(transp *http.Transport, host string, port int, statusCodes []int,
 		}
 	}
 
+	if c.MaxResponseTime > 0 && elapsed > time.Duration(c.MaxResponseTime)*time.Millisecond {
+		return base.FailResult(fmt.Sprintf("Response took %dms, limit is %dms", elapsed.Milliseconds(), c.MaxResponseTime))
+	}
+
+	if c.BodyContains != "" && !bytes.Contains(body, []byte(c.BodyContains)) {
+		return base.FailResult(fmt.Sprintf("Body does not contain %q", c.BodyContains))
+	}
+
+	if c.BodyRegex != "" && !regexp.MustCompile(c.BodyRegex).Match(body) {
+		return base.FailResult(fmt.Sprintf("Body does not match %q", c.BodyRegex))
+	}
+
+	if c.JsonPath != "" {
+		if msg, ok := verifyJsonPath(body, c.JsonPath, c.JsonValue); !ok {
+			return base.FailResult(msg)
+		}
+	}
+
 	return base.GoodResult()
 }
+
+// verifyJsonPath checks that the value at path in the json document equals expected.
+// If expected is nil we only check that the path exists.
+func verifyJsonPath(body []byte, path string, expected interface{}) (string, bool) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return fmt.Sprintf("Body is not valid json: %s", err), false
+	}
+	value, found := jsonPathLookup(doc, path)
+	if !found {
+		return fmt.Sprintf("No value at json path %s", path), false
+	}
+	if expected == nil {
+		return "", true
+	}
+
+	// Normalize expected so that it has the same types as values parsed from json
+	var normalized interface{}
+	b, err := json.Marshal(expected)
+	if err == nil {
+		err = json.Unmarshal(b, &normalized)
+	}
+	if err != nil || !reflect.DeepEqual(value, normalized) {
+		got, _ := json.Marshal(value)
+		return fmt.Sprintf("Unexpected value at json path %s: %s, expected %s", path, got, b), false
+	}
+	return "", true
+}
+
+// jsonPathLookup finds the value at a simple path such as "status", "$.status" or "checks[0].name"
+func jsonPathLookup(doc interface{}, path string) (interface{}, bool) {
+	path = strings.TrimPrefix(path, "$")
+	path = strings.ReplaceAll(path, "[", ".")
+	path = strings.ReplaceAll(path, "]", "")
+	path = strings.TrimPrefix(path, ".")
+	if path == "" {
+		return doc, true
+	}
+
+	current := doc
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			current = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
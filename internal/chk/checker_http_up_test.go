@@ -0,0 +1,115 @@
+package chk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func startHttpServer(t *testing.T) (string, int) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"status":"ok","checks":[{"name":"db","up":true}]}`)
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s %s", r.Method, r.Header.Get("X-Token"), body)
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/healthz", http.StatusFound)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return host, p
+}
+
+func TestHttpUpAssertions(t *testing.T) {
+	host, port := startHttpServer(t)
+
+	cases := []struct {
+		checker HttpUpChecker
+		status  string
+	}{
+		{HttpUpChecker{Host: host, Port: port}, "fail"},
+		{HttpUpChecker{Host: host, Port: port, StatusCodes: []int{404}}, "good"},
+		{HttpUpChecker{Host: host, Port: port, Path: "/healthz"}, "good"},
+		{HttpUpChecker{Host: host, Port: port, Path: "/healthz", BodyContains: `"ok"`}, "good"},
+		{HttpUpChecker{Host: host, Port: port, Path: "/healthz", BodyContains: "degraded"}, "fail"},
+		{HttpUpChecker{Host: host, Port: port, Path: "/healthz", BodyRegex: `"status":\s*"ok"`}, "good"},
+		{HttpUpChecker{Host: host, Port: port, Path: "/healthz", JsonPath: "status", JsonValue: "ok"}, "good"},
+		{HttpUpChecker{Host: host, Port: port, Path: "/healthz", JsonPath: "$.checks[0].up", JsonValue: true}, "good"},
+		{HttpUpChecker{Host: host, Port: port, Path: "/healthz", JsonPath: "status", JsonValue: "degraded"}, "fail"},
+		{HttpUpChecker{Host: host, Port: port, Path: "/healthz", JsonPath: "missing"}, "fail"},
+		{HttpUpChecker{Host: host, Port: port, Path: "/redirect"}, "fail"},
+		{HttpUpChecker{Host: host, Port: port, Path: "/redirect", FollowRedirects: true}, "good"},
+		{HttpUpChecker{Host: host, Port: port, Path: "/slow", MaxResponseTime: 50}, "fail"},
+		{HttpUpChecker{Host: host, Port: port, Path: "/slow", MaxResponseTime: 5000}, "good"},
+		{HttpUpChecker{
+			Host:         host,
+			Port:         port,
+			Path:         "/echo",
+			Method:       "post",
+			Headers:      map[string]string{"X-Token": "secret"},
+			Body:         "hello",
+			BodyContains: "POST secret hello",
+		}, "good"},
+	}
+	for _, c := range cases {
+		if err := c.checker.Validate(); err != nil {
+			t.Fatalf("Invalid checker %+v: %s", c.checker, err)
+		}
+		res := c.checker.Run(NewContext())
+		if res.Status != c.status {
+			t.Errorf("Expected %s for %+v, got %s: %s", c.status, c.checker, res.Status, res.Msg)
+		}
+	}
+}
+
+func TestHttpUpUnmarshalJsonValue(t *testing.T) {
+	host, port := startHttpServer(t)
+
+	check, err := New("http-up", "ns", 60, []byte(fmt.Sprintf(
+		`{"host":"%s","port":%d,"path":"/healthz","json_path":"status","json_value":"ok"}`, host, port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.Title() != fmt.Sprintf("http:%s:%d/healthz", host, port) {
+		t.Fatalf("Wrong title: %s", check.Title())
+	}
+	res := check.Checker.Run(NewContext())
+	if res.Status != "good" {
+		t.Fatalf("Expected good, got %s: %s", res.Status, res.Msg)
+	}
+}
+
+func TestHttpUpValidate(t *testing.T) {
+	if err := (HttpUpChecker{Host: "example.com", Path: "healthz"}).Validate(); err == nil {
+		t.Error("Expected error for relative path")
+	}
+	if err := (HttpUpChecker{Host: "example.com", BodyRegex: "("}).Validate(); err == nil {
+		t.Error("Expected error for bad regex")
+	}
+	if err := (HttpUpChecker{Host: "example.com", Method: "GE T"}).Validate(); err == nil {
+		t.Error("Expected error for bad method")
+	}
+}
+
+func TestHttpUpJsonUnchanged(t *testing.T) {
+	// Checks are identified by their json so existing checks must serialize as before
+	c := HttpUpChecker{Host: "example.com", StatusCodes: []int{200}}
+	if string(c.AsJson()) != `{"host":"example.com","status_codes":[200]}` {
+		t.Fatalf("Unexpected json: %s", c.AsJson())
+	}
+}
@@ -0,0 +1,109 @@
+package chk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/base"
+)
+
+type TcpPortChecker struct {
+	Host    string `json:"host"`
+	Port    int    `json:"port"`
+	Send    string `json:"send,omitempty"`
+	Expect  string `json:"expect,omitempty"`
+	Timeout int    `json:"timeout,omitempty"`
+}
+
+func (c TcpPortChecker) Title() string {
+	return fmt.Sprintf("tcp:%s:%d", c.Host, c.Port)
+}
+
+func (c TcpPortChecker) Type() string {
+	return "tcp-port"
+}
+
+func (c TcpPortChecker) Validate() error {
+	if c.Host == "" {
+		return errors.New("Empty host in tcp-port check")
+	}
+	if c.Port <= 0 || c.Port > 65535 {
+		return fmt.Errorf("Invalid port in tcp-port check: %d", c.Port)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("Invalid timeout in tcp-port check: %d", c.Timeout)
+	}
+	if _, err := regexp.Compile(c.Expect); err != nil {
+		return fmt.Errorf("Invalid expect regex in tcp-port check: %w", err)
+	}
+	return nil
+}
+
+func (c TcpPortChecker) AsJson() []byte {
+	b, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func (c TcpPortChecker) timeoutOrDefault() time.Duration {
+	if c.Timeout == 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(c.Timeout) * time.Second
+}
+
+func (c TcpPortChecker) Run(ctx *Context) base.Result {
+	timeout := c.timeoutOrDefault()
+	deadline := time.Now().Add(timeout)
+
+	addr := net.JoinHostPort(c.Host, fmt.Sprint(c.Port))
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return networkErrorResult(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(deadline)
+
+	if c.Send != "" {
+		_, err := conn.Write([]byte(c.Send))
+		if err != nil {
+			return base.FailResult(fmt.Sprintf("Couldn't send probe: %s", err))
+		}
+	}
+
+	if c.Expect != "" {
+		re := regexp.MustCompile(c.Expect)
+		banner, err := readUntilMatch(conn, re)
+		if err != nil {
+			return base.FailResult(fmt.Sprintf("Banner %q does not match %q: %s", banner, c.Expect, err))
+		}
+	}
+
+	return base.GoodResult()
+}
+
+// readUntilMatch reads from conn until the data matches re, the connection is closed or a deadline is hit.
+// At most 64KiB is read.
+func readUntilMatch(conn net.Conn, re *regexp.Regexp) ([]byte, error) {
+	const maxLen = 64 * 1024
+	var buf bytes.Buffer
+	chunk := make([]byte, 4096)
+	for buf.Len() < maxLen {
+		n, err := conn.Read(chunk)
+		buf.Write(chunk[:n])
+		if re.Match(buf.Bytes()) {
+			return buf.Bytes(), nil
+		}
+		if err != nil {
+			return buf.Bytes(), err
+		}
+	}
+	return buf.Bytes(), errors.New("Too much data")
+}
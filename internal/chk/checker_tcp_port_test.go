@@ -0,0 +1,102 @@
+package chk
+
+import (
+	"bufio"
+	"net"
+	"testing"
+)
+
+func startTcpServer(t *testing.T, handle func(conn net.Conn)) (string, int) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	addr := l.Addr().(*net.TCPAddr)
+	return "127.0.0.1", addr.Port
+}
+
+func TestTcpPortUp(t *testing.T) {
+	host, port := startTcpServer(t, func(conn net.Conn) {
+		conn.Write([]byte("220 smtp.example.com ESMTP\r\n"))
+	})
+
+	res := TcpPortChecker{Host: host, Port: port}.Run(NewContext())
+	if res.Status != "good" {
+		t.Fatalf("Expected good, got %s: %s", res.Status, res.Msg)
+	}
+
+	res = TcpPortChecker{Host: host, Port: port, Expect: "^220 .*ESMTP", Timeout: 2}.Run(NewContext())
+	if res.Status != "good" {
+		t.Fatalf("Expected good, got %s: %s", res.Status, res.Msg)
+	}
+
+	res = TcpPortChecker{Host: host, Port: port, Expect: "^SSH-", Timeout: 2}.Run(NewContext())
+	if res.Status != "fail" {
+		t.Fatalf("Expected fail, got %s", res.Status)
+	}
+}
+
+func TestTcpPortProbe(t *testing.T) {
+	host, port := startTcpServer(t, func(conn net.Conn) {
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			return
+		}
+		if line == "PING\r\n" {
+			conn.Write([]byte("+PONG\r\n"))
+		}
+	})
+
+	res := TcpPortChecker{Host: host, Port: port, Send: "PING\r\n", Expect: `\+PONG`, Timeout: 2}.Run(NewContext())
+	if res.Status != "good" {
+		t.Fatalf("Expected good, got %s: %s", res.Status, res.Msg)
+	}
+}
+
+func TestTcpPortClosed(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	res := TcpPortChecker{Host: "127.0.0.1", Port: port, Timeout: 2}.Run(NewContext())
+	if res.Status != "fail" {
+		t.Fatalf("Expected fail, got %s", res.Status)
+	}
+}
+
+func TestTcpPortUnmarshal(t *testing.T) {
+	check, err := New("tcp-port", "db", 60, []byte(`{"host":"db.example.com","port":5432}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.Title() != "tcp:db.example.com:5432" {
+		t.Fatalf("Wrong title: %s", check.Title())
+	}
+	if err := check.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	check, err = New("tcp-port", "db", 60, []byte(`{"host":"db.example.com"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.Validate() == nil {
+		t.Fatal("Expected error for missing port")
+	}
+}
@@ -15,6 +15,12 @@ func (c Check) MarshalJSON() ([]byte, error) {
 	mp["interval"] = c.Interval
 	mp["namespace"] = c.Namespace
 	mp["type"] = c.Type
+	if c.AlertAfter != 0 {
+		mp["alert_after"] = c.AlertAfter
+	}
+	if c.RecoverAfter != 0 {
+		mp["recover_after"] = c.RecoverAfter
+	}
 	err = json.Unmarshal(bs, &mp)
 	if err != nil {
 		return nil, err
@@ -82,6 +88,41 @@ func unmarshalChecker(typ string, jsonData []byte) (Checker, error) {
 			return nil, fmt.Errorf("Error parsing %s check: %w", typ, err)
 		}
 		return checker, nil
+	case "tcp-port":
+		var checker TcpPortChecker
+		err := json.Unmarshal(jsonData, &checker)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing %s check: %w", typ, err)
+		}
+		return checker, nil
+	case "disk-free":
+		var checker DiskFreeChecker
+		err := json.Unmarshal(jsonData, &checker)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing %s check: %w", typ, err)
+		}
+		return checker, nil
+	case "dns":
+		var checker DnsChecker
+		err := json.Unmarshal(jsonData, &checker)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing %s check: %w", typ, err)
+		}
+		return checker, nil
+	case "domain-expiry":
+		var checker DomainExpiryChecker
+		err := json.Unmarshal(jsonData, &checker)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing %s check: %w", typ, err)
+		}
+		return checker, nil
+	case "exec":
+		var checker ExecChecker
+		err := json.Unmarshal(jsonData, &checker)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing %s check: %w", typ, err)
+		}
+		return checker, nil
 	default:
 		return nil, fmt.Errorf("Unkown Check type: %s", typ)
 	}
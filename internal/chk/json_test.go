@@ -94,3 +94,31 @@ func TestUnmarshalError(t *testing.T) {
 		t.Fatalf("Unexpected error: %s", err)
 	}
 }
+
+func TestMarshalThresholds(t *testing.T) {
+	var check Check
+	input := `{"type": "http-up", "interval": 60, "host": "example.com", "alert_after": 3, "recover_after": 2}`
+	err := json.Unmarshal([]byte(input), &check)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if check.AlertAfter != 3 || check.RecoverAfter != 2 {
+		t.Fatalf("Wrong thresholds: %+v", check)
+	}
+	if string(check.Checker.AsJson()) != `{"host":"example.com"}` {
+		t.Fatalf("Thresholds should not be part of checker: %s", check.Checker.AsJson())
+	}
+
+	bytes, err := json.Marshal(check)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bytes) != `{"alert_after":3,"host":"example.com","interval":60,"namespace":"","recover_after":2,"type":"http-up"}` {
+		t.Fatalf("Unexpected json: %s", bytes)
+	}
+
+	err = json.Unmarshal([]byte(`{"type": "http-up", "interval": 60, "host": "example.com", "alert_after": -1}`), &check)
+	if err == nil {
+		t.Fatal("Expected error for negative alert_after")
+	}
+}
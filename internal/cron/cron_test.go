@@ -0,0 +1,118 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParse(t *testing.T) {
+	for _, spec := range []string{"* * * * *", "*/15 2 * * 0", "0,30 8-17 * * 1-5", "0 0 1 1,6 *", "0 3 * * 7", "5-50/5 * * * *"} {
+		if _, err := Parse(spec); err != nil {
+			t.Errorf("Unexpected error for %q: %s", spec, err)
+		}
+	}
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	// 2022-01-02 is a sunday
+	s, _ := Parse("30 2 * * 0")
+	if !s.Matches(date("2022-01-02 02:30")) {
+		t.Error("Expected match on sunday 02:30")
+	}
+	if s.Matches(date("2022-01-03 02:30")) {
+		t.Error("Expected no match on monday")
+	}
+
+	s, _ = Parse("0 3 * * 7")
+	if !s.Matches(date("2022-01-02 03:00")) {
+		t.Error("Expected 7 to be sunday")
+	}
+
+	// Either day of month or day of week
+	s, _ = Parse("0 0 15 * 1")
+	if !s.Matches(date("2022-01-15 00:00")) || !s.Matches(date("2022-01-03 00:00")) {
+		t.Error("Expected match on the 15th and on mondays")
+	}
+	if s.Matches(date("2022-01-04 00:00")) {
+		t.Error("Expected no match on tuesday the 4th")
+	}
+}
+
+func TestNext(t *testing.T) {
+	cases := []struct {
+		spec, from, expected string
+	}{
+		{"*/15 * * * *", "2022-01-01 10:07", "2022-01-01 10:15"},
+		{"*/15 * * * *", "2022-01-01 10:15", "2022-01-01 10:30"},
+		{"30 2 * * 0", "2022-01-01 10:00", "2022-01-02 02:30"},
+		{"0 0 1 3 *", "2022-04-01 00:00", "2023-03-01 00:00"},
+		{"0 12 29 2 *", "2022-01-01 00:00", "2024-02-29 12:00"},
+	}
+	for _, c := range cases {
+		s, err := Parse(c.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next := s.Next(date(c.from))
+		if !next.Equal(date(c.expected)) {
+			t.Errorf("Next of %q from %s: expected %s, got %s", c.spec, c.from, c.expected, next)
+		}
+	}
+}
+
+func TestNextInLocation(t *testing.T) {
+	cases := []struct {
+		zone, spec, from, expected string
+	}{
+		// Half hour offset
+		{"Asia/Kolkata", "0 11 * * *", "2022-01-01 10:07", "2022-01-01 11:00"},
+		{"Asia/Kolkata", "30 * * * *", "2022-01-01 10:45", "2022-01-01 11:30"},
+		// Europe/Stockholm skips 02:00-03:00 on 2022-03-27 and repeats 02:00-03:00 on 2022-10-30
+		{"Europe/Stockholm", "0 3 * * *", "2022-03-27 01:30", "2022-03-27 03:00"},
+		{"Europe/Stockholm", "30 2 * * *", "2022-03-27 01:00", "2022-03-28 02:30"},
+		{"Europe/Stockholm", "0 4 * * *", "2022-10-30 01:30", "2022-10-30 04:00"},
+	}
+	for _, c := range cases {
+		loc, err := time.LoadLocation(c.zone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := Parse(c.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		from, _ := time.ParseInLocation("2006-01-02 15:04", c.from, loc)
+		expected, _ := time.ParseInLocation("2006-01-02 15:04", c.expected, loc)
+		next := s.Next(from)
+		if !next.Equal(expected) {
+			t.Errorf("Next of %q from %s in %s: expected %s, got %s", c.spec, c.from, c.zone, expected, next)
+		}
+	}
+}
+
+func TestActiveAt(t *testing.T) {
+	s, _ := Parse("0 2 * * 0")
+	hour := time.Hour
+	if !s.ActiveAt(date("2022-01-02 02:00"), hour) || !s.ActiveAt(date("2022-01-02 02:59"), hour) {
+		t.Error("Expected active within the hour after 02:00 on sunday")
+	}
+	if s.ActiveAt(date("2022-01-02 03:00"), hour) || s.ActiveAt(date("2022-01-02 01:59"), hour) {
+		t.Error("Expected inactive outside the window")
+	}
+	if s.ActiveAt(date("2022-01-03 02:30"), hour) {
+		t.Error("Expected inactive on monday")
+	}
+}
@@ -2,6 +2,8 @@ package hubutil
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
@@ -17,6 +19,81 @@ type HubConfig struct {
 	SMTPUser     string `json:"smtp_user"`
 	SMTPPassword string `json:"smtp_password"`
 	SMTPFrom     string `json:"smtp_from"`
+	// Non-good statuses that we send notifications for. Recoveries are always notified.
+	NotifyStatuses []string `json:"notify_statuses"`
+	// Notification targets in addition to notify_email
+	Notifiers []NotifierConfig `json:"notifiers"`
+	// Flap detection in percent weighted state changes over the recent results.
+	// A check starts flapping above the high threshold and stops below the low threshold.
+	DisableFlapDetection bool    `json:"disable_flap_detection"`
+	FlapHighThreshold    float64 `json:"flap_high_threshold"`
+	FlapLowThreshold     float64 `json:"flap_low_threshold"`
+	// Resend notifications with this interval, eg "4h", while a check remains in a non-good status.
+	// Empty means no reminders.
+	ReminderInterval string `json:"reminder_interval"`
+	// Scheduled summaries of the hub state
+	Digests []DigestConfig `json:"digests"`
+	// How uptime reports count periods where a check has expired, "unknown" (default) or "down"
+	SlaExpiredAs string `json:"sla_expired_as"`
+	// How long results and events are kept
+	Retention RetentionConfig `json:"retention"`
+	// Routes decides which notifiers get a notification. Without routes all notifiers get everything.
+	Routes []RouteConfig `json:"routes"`
+}
+
+// NotifierConfig configures a notification target. Which fields are used depends on the type:
+//
+//	email:    to
+//	webhook:  url, headers
+//	slack:    url (slack or mattermost incoming webhook)
+//	ntfy:     url (including topic), token
+//	gotify:   url, token
+//	telegram: token, chat_id, url (defaults to https://api.telegram.org)
+type NotifierConfig struct {
+	Name    string            `json:"name"`
+	Type    string            `json:"type"`
+	URL     string            `json:"url,omitempty"`
+	Token   string            `json:"token,omitempty"`
+	ChatID  string            `json:"chat_id,omitempty"`
+	To      string            `json:"to,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// RouteConfig sends matching notifications to the named targets. Empty fields match anything.
+// Namespace and agent are glob patterns, eg "db:*". From and to are lists of statuses for the
+// status transition, where a check without earlier notifications counts as coming from "good".
+type RouteConfig struct {
+	Namespace string   `json:"namespace,omitempty"`
+	Agent     string   `json:"agent,omitempty"`
+	CheckType string   `json:"check_type,omitempty"`
+	From      []string `json:"from,omitempty"`
+	To        []string `json:"to,omitempty"`
+	Targets   []string `json:"targets"`
+	// Escalations notify more targets when a problem remains unacked
+	Escalations []EscalationConfig `json:"escalations,omitempty"`
+}
+
+// DigestConfig sends a digest of the last period, eg "7d", on a cron schedule, eg "0 8 * * 1", to the
+// named targets. Without targets the digest goes to all notifiers.
+type DigestConfig struct {
+	Name     string   `json:"name"`
+	Schedule string   `json:"schedule"`
+	Period   string   `json:"period"`
+	Targets  []string `json:"targets,omitempty"`
+}
+
+// RetentionConfig keeps raw results for raw_days. Older results are compacted to status transitions and
+// hourly rollups. Results and events older than keep_days are deleted. Zero means forever.
+type RetentionConfig struct {
+	RawDays  int `json:"raw_days"`
+	KeepDays int `json:"keep_days"`
+}
+
+// EscalationConfig notifies the targets if a check is still in a non-good status and unacked a
+// duration, eg "30m", after the problem was first notified
+type EscalationConfig struct {
+	After   string   `json:"after"`
+	Targets []string `json:"targets"`
 }
 
 func (cfg HubConfig) Database() string {
@@ -31,6 +108,24 @@ func (cfg HubConfig) KeyFile() string {
 	return path.Join(cfg.DataDir, "key.pem")
 }
 
+// ShouldNotify returns true if a change to status should be notified
+func (cfg HubConfig) ShouldNotify(status string) bool {
+	if status == "good" {
+		return true
+	}
+	for _, s := range cfg.NotifyStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpiredAsDown returns true if expired periods count as downtime in uptime reports
+func (cfg HubConfig) ExpiredAsDown() bool {
+	return cfg.SlaExpiredAs == "down"
+}
+
 func ReadConfig(filename string) (HubConfig, error) {
 	contents, err := ioutil.ReadFile(filename)
 	if err != nil {
@@ -49,5 +144,23 @@ func ReadConfig(filename string) (HubConfig, error) {
 	if cfg.Port == 0 {
 		cfg.Port = 4433
 	}
+	if cfg.NotifyStatuses == nil {
+		cfg.NotifyStatuses = []string{"warning", "fail", "error", "expired", "flapping"}
+	}
+	if cfg.FlapHighThreshold == 0 {
+		cfg.FlapHighThreshold = 20
+	}
+	if cfg.FlapLowThreshold == 0 {
+		cfg.FlapLowThreshold = 5
+	}
+	if cfg.Retention.RawDays < 0 || cfg.Retention.KeepDays < 0 {
+		return HubConfig{}, errors.New("Invalid retention, days can't be negative")
+	}
+	if cfg.SlaExpiredAs == "" {
+		cfg.SlaExpiredAs = "unknown"
+	}
+	if cfg.SlaExpiredAs != "unknown" && cfg.SlaExpiredAs != "down" {
+		return HubConfig{}, fmt.Errorf("Invalid sla_expired_as %q, expected unknown or down", cfg.SlaExpiredAs)
+	}
 	return cfg, nil
 }
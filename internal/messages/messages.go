@@ -2,6 +2,7 @@ package messages
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/rymdhund/whazza/internal/base"
 	"github.com/rymdhund/whazza/internal/chk"
@@ -21,8 +22,26 @@ func NewCheckResultMsg(check chk.Check, result base.Result) CheckResultMsg {
 }
 
 func (cr CheckResultMsg) Validate() (bool, string) {
-	if cr.Result.Status != "fail" && cr.Result.Status != "good" {
+	if !base.IsReportable(cr.Result.Status) {
 		return false, fmt.Sprintf("Invalid status: %s", cr.Result.Status)
 	}
 	return true, ""
 }
+
+// Max number of results in a batch sent to /agent/results
+const MaxBatchSize = 500
+
+// ResultStatusMsg is the hub response for each result in a batch
+type ResultStatusMsg struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Messages from hub
+
+// CheckStateMsg describes a check of an agent as registered on the hub
+type CheckStateMsg struct {
+	Check chk.Check
+	// When the last result was produced, zero if the hub has none
+	LastRun time.Time
+}
@@ -9,14 +9,35 @@ import (
 )
 
 func TestDeserializeXX(t *testing.T) {
-	msg := NewCheckResultMsg(
-		chk.Check{},
-		base.Result{},
-	)
-	msg.Check.Type = "http-up"
+	// A check without checker marshals to null, so we need a real one to deserialize
+	check, err := chk.New("http-up", "ns", 60, []byte(`{"host":"example.com"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := NewCheckResultMsg(check, base.Result{})
 	bs, _ := json.Marshal(msg)
-	json.Unmarshal(bs, msg)
-	if msg.Check.Type != "http-up" {
+
+	var msg2 CheckResultMsg
+	err = json.Unmarshal(bs, &msg2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg2.Check.Type != "http-up" {
 		t.Error("Expected http-up")
 	}
 }
+
+func TestValidateStatus(t *testing.T) {
+	for _, status := range []string{"good", "warning", "fail", "error"} {
+		msg := NewCheckResultMsg(chk.Check{}, base.Result{Status: status})
+		if ok, e := msg.Validate(); !ok {
+			t.Errorf("Expected %s to be valid: %s", status, e)
+		}
+	}
+	for _, status := range []string{"", "expired", "bad"} {
+		msg := NewCheckResultMsg(chk.Check{}, base.Result{Status: status})
+		if ok, _ := msg.Validate(); ok {
+			t.Errorf("Expected %s to be invalid", status)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/cron"
+	"github.com/rymdhund/whazza/internal/hubutil"
+	. "github.com/rymdhund/whazza/internal/logging"
+	"github.com/rymdhund/whazza/internal/persist"
+	"github.com/rymdhund/whazza/internal/report"
+	"github.com/rymdhund/whazza/internal/utils"
+)
+
+type digest struct {
+	name     string
+	schedule cron.Schedule
+	period   time.Duration
+	targets  []string
+}
+
+func parseDigests(configs []hubutil.DigestConfig, notifiers []namedNotifier) ([]digest, error) {
+	digests := []digest{}
+	seen := map[string]bool{}
+	for _, dc := range configs {
+		if dc.Name == "" || seen[dc.Name] {
+			return nil, fmt.Errorf("Digests need unique names, got %q", dc.Name)
+		}
+		seen[dc.Name] = true
+		schedule, err := cron.Parse(dc.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid schedule for digest %s: %w", dc.Name, err)
+		}
+		period, err := utils.ParseDuration(dc.Period)
+		if err != nil || period <= 0 {
+			return nil, fmt.Errorf("Invalid period for digest %s: %q", dc.Name, dc.Period)
+		}
+		for _, target := range dc.Targets {
+			if findNotifier(notifiers, target) == nil {
+				return nil, fmt.Errorf("Unknown target %q in digest %s", target, dc.Name)
+			}
+		}
+		digests = append(digests, digest{dc.Name, schedule, period, dc.Targets})
+	}
+	return digests, nil
+}
+
+// SendDigests sends the digests that are due since they were last sent
+func (m *Monitor) SendDigests() error {
+	return m.sendDigests(time.Now())
+}
+
+func (m *Monitor) sendDigests(now time.Time) error {
+	if len(m.digests) == 0 {
+		return nil
+	}
+	db, err := persist.Open(m.cfg.Database())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	for _, d := range m.digests {
+		lastRun, err := db.LastDigestRun(d.name)
+		if err != nil {
+			return err
+		}
+		// New digests are sent the first time they are scheduled after the hub started. A digest that was
+		// due while the hub was down is sent once when it starts.
+		from := lastRun
+		if from.IsZero() {
+			from = m.hubStartTime
+		}
+		next := d.schedule.Next(from)
+		if next.IsZero() || next.After(now) {
+			continue
+		}
+
+		flapThreshold := 0.0
+		if m.flapDetection() {
+			flapThreshold = m.cfg.FlapHighThreshold
+		}
+		opts := report.Options{FlapThreshold: flapThreshold, ExpiredAsDown: m.cfg.ExpiredAsDown()}
+		dg, err := report.BuildDigest(db, now.Add(-d.period), now, opts)
+		if err != nil {
+			return err
+		}
+
+		InfoLog.Printf("Sending digest %s", d.name)
+		targets := m.notifiers
+		if len(d.targets) > 0 {
+			targets = m.notifiersByName(d.targets)
+		}
+		err = sendAll(targets, func(notifier Notifier) error { return notifier.SendMessage(dg.Subject(), dg.Text()) })
+		if err != nil {
+			return err
+		}
+		err = db.SetDigestRun(d.name, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package monitor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/hubutil"
+)
+
+func TestSendDigests(t *testing.T) {
+	m, db, notifications := mkTestMonitor(t)
+	var err error
+	m.digests, err = parseDigests([]hubutil.DigestConfig{{Name: "weekly", Schedule: "0 8 * * 1", Period: "7d"}}, m.notifiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := mkTestCheck(t, db, "web")
+	addResult(t, m, db, check, "fail")
+	*notifications = nil
+
+	// Monday 2022-01-03 08:00 local time
+	monday := time.Date(2022, 1, 3, 8, 0, 0, 0, time.Local)
+	m.hubStartTime = monday.Add(-time.Hour)
+
+	if err := m.sendDigests(monday.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if len(*notifications) != 0 {
+		t.Fatalf("Expected no digest before schedule, got %v", *notifications)
+	}
+
+	if err := m.sendDigests(monday.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if len(*notifications) != 1 || (*notifications)[0].Kind != "message" {
+		t.Fatalf("Expected a digest, got %v", *notifications)
+	}
+	msg := (*notifications)[0].Result.Msg
+	if !strings.Contains(msg, "Failing checks (1)") || !strings.Contains(msg, "http:example.com") {
+		t.Fatalf("Unexpected digest:\n%s", msg)
+	}
+
+	// Only sent once per schedule
+	if err := m.sendDigests(monday.Add(2 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.sendDigests(monday.Add(7 * 24 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if len(*notifications) != 2 {
+		t.Fatalf("Expected two digests, got %d", len(*notifications))
+	}
+}
+
+func TestSendOverdueDigest(t *testing.T) {
+	m, db, notifications := mkTestMonitor(t)
+	var err error
+	m.digests, err = parseDigests([]hubutil.DigestConfig{{Name: "weekly", Schedule: "0 8 * * 1", Period: "7d"}}, m.notifiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	*notifications = nil
+
+	// The hub was down when the digest was due on Monday 2022-01-03 08:00
+	monday := time.Date(2022, 1, 3, 8, 0, 0, 0, time.Local)
+	if err := db.SetDigestRun("weekly", monday.Add(-7*24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	m.hubStartTime = monday.Add(5 * time.Minute)
+
+	for _, now := range []time.Time{monday.Add(6 * time.Minute), monday.Add(7 * time.Minute)} {
+		if err := m.sendDigests(now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*notifications) != 1 {
+		t.Fatalf("Expected the overdue digest once, got %d", len(*notifications))
+	}
+}
+
+func TestParseDigests(t *testing.T) {
+	invalid := [][]hubutil.DigestConfig{
+		{{Name: "", Schedule: "0 8 * * 1", Period: "7d"}},
+		{{Name: "a", Schedule: "0 8 * *", Period: "7d"}},
+		{{Name: "a", Schedule: "0 8 * * 1", Period: ""}},
+		{{Name: "a", Schedule: "0 8 * * 1", Period: "7d", Targets: []string{"nobody"}}},
+		{{Name: "a", Schedule: "0 8 * * 1", Period: "7d"}, {Name: "a", Schedule: "0 8 * * 1", Period: "1d"}},
+	}
+	for _, configs := range invalid {
+		if _, err := parseDigests(configs, nil); err == nil {
+			t.Errorf("Expected error for %+v", configs)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package monitor
+
+import (
+	"fmt"
+
+	"github.com/rymdhund/whazza/internal/base"
+	"github.com/rymdhund/whazza/internal/chk"
+	"github.com/rymdhund/whazza/internal/persist"
+	"github.com/rymdhund/whazza/internal/report"
+)
+
+// consecutive counts the newest results that are good, or non-good, like the newest result
+func consecutive(recent []persist.ResultModel) int {
+	n := 0
+	for _, r := range recent {
+		if (r.Status == "good") != (recent[0].Status == "good") {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+func historyLen(check chk.Check) int {
+	n := report.FlapWindow
+	if check.AlertAfter > n {
+		n = check.AlertAfter
+	}
+	if check.RecoverAfter > n {
+		n = check.RecoverAfter
+	}
+	return n
+}
+
+func (m *Monitor) flapDetection() bool {
+	return !m.cfg.DisableFlapDetection && m.cfg.FlapHighThreshold > 0
+}
+
+// nextNotification decides what to notify given the last notified status and the recent results of a check, newest first.
+// It returns false if nothing should be notified.
+func (m *Monitor) nextNotification(check chk.Check, oldStatus string, recent []persist.ResultModel) (base.Result, bool) {
+	if len(recent) == 0 {
+		return base.Result{}, false
+	}
+	res := recent[0].Result
+
+	if m.flapDetection() {
+		percent := report.FlapPercent(recent)
+		if oldStatus == "flapping" {
+			if percent >= m.cfg.FlapLowThreshold {
+				return base.Result{}, false
+			}
+		} else if percent >= m.cfg.FlapHighThreshold {
+			msg := fmt.Sprintf("Check is flapping with %.0f%% state changes, notifications are suppressed until it is stable", percent)
+			return base.Result{Status: "flapping", Msg: msg, Timestamp: res.Timestamp}, true
+		}
+	}
+
+	if res.Status == oldStatus {
+		return base.Result{}, false
+	}
+
+	needed := check.AlertAfter
+	if res.Status == "good" {
+		needed = check.RecoverAfter
+	}
+	if consecutive(recent) < needed {
+		return base.Result{}, false
+	}
+	return res, true
+}
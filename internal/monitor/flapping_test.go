@@ -0,0 +1,79 @@
+package monitor
+
+import (
+	"testing"
+
+	"github.com/rymdhund/whazza/internal/base"
+	"github.com/rymdhund/whazza/internal/chk"
+	"github.com/rymdhund/whazza/internal/hubutil"
+	"github.com/rymdhund/whazza/internal/persist"
+	"github.com/rymdhund/whazza/internal/report"
+)
+
+// mkResults makes results from statuses given oldest first, returned newest first
+func mkResults(statuses ...string) []persist.ResultModel {
+	results := []persist.ResultModel{}
+	for i := len(statuses) - 1; i >= 0; i-- {
+		results = append(results, persist.ResultModel{Result: base.Result{Status: statuses[i]}})
+	}
+	return results
+}
+
+func TestNextNotificationThresholds(t *testing.T) {
+	m := &Monitor{cfg: hubutil.HubConfig{DisableFlapDetection: true}}
+	check, _ := chk.New("http-up", "web", 60, []byte(`{"host": "example.com"}`))
+	check.AlertAfter = 3
+	check.RecoverAfter = 2
+
+	if _, ok := m.nextNotification(check, "good", mkResults("good", "fail", "fail")); ok {
+		t.Error("Expected no alert after two fails")
+	}
+	res, ok := m.nextNotification(check, "good", mkResults("good", "fail", "warning", "fail"))
+	if !ok || res.Status != "fail" {
+		t.Errorf("Expected fail alert after three non-good results, got %v %v", ok, res)
+	}
+	if _, ok := m.nextNotification(check, "fail", mkResults("fail", "fail", "good")); ok {
+		t.Error("Expected no recovery after one good")
+	}
+	res, ok = m.nextNotification(check, "fail", mkResults("fail", "good", "good"))
+	if !ok || res.Status != "good" {
+		t.Errorf("Expected recovery after two goods, got %v %v", ok, res)
+	}
+
+	// Without thresholds every change is notified
+	check.AlertAfter = 0
+	check.RecoverAfter = 0
+	res, ok = m.nextNotification(check, "good", mkResults("good", "fail"))
+	if !ok || res.Status != "fail" {
+		t.Errorf("Expected fail alert, got %v %v", ok, res)
+	}
+	if _, ok := m.nextNotification(check, "fail", mkResults("good", "fail")); ok {
+		t.Error("Expected no notification without status change")
+	}
+}
+
+func TestNextNotificationFlapping(t *testing.T) {
+	m := &Monitor{cfg: hubutil.HubConfig{FlapHighThreshold: 20, FlapLowThreshold: 5}}
+	check, _ := chk.New("http-up", "web", 60, []byte(`{"host": "example.com"}`))
+
+	flappy := mkResults("good", "good", "fail", "good", "fail", "good", "fail")
+	res, ok := m.nextNotification(check, "good", flappy)
+	if !ok || res.Status != "flapping" {
+		t.Fatalf("Expected flapping notification, got %v %v", ok, res)
+	}
+
+	// No notifications while flapping
+	if _, ok := m.nextNotification(check, "flapping", mkResults("good", "fail", "good", "fail", "good")); ok {
+		t.Error("Expected no notification while flapping")
+	}
+
+	// When the check is stable we notify the current status
+	stable := []string{"fail", "good"}
+	for i := 0; i < report.FlapWindow; i++ {
+		stable = append(stable, "good")
+	}
+	res, ok = m.nextNotification(check, "flapping", mkResults(stable...))
+	if !ok || res.Status != "good" {
+		t.Errorf("Expected good notification when stable, got %v %v", ok, res)
+	}
+}
@@ -0,0 +1,139 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/hubutil"
+	. "github.com/rymdhund/whazza/internal/logging"
+	"github.com/rymdhund/whazza/internal/persist"
+	"github.com/rymdhund/whazza/internal/utils"
+)
+
+type escalation struct {
+	after   time.Duration
+	targets []string
+}
+
+func parseFollowUps(cfg hubutil.HubConfig) (time.Duration, [][]escalation, error) {
+	var reminderInterval time.Duration
+	if cfg.ReminderInterval != "" {
+		var err error
+		reminderInterval, err = utils.ParseDuration(cfg.ReminderInterval)
+		if err != nil || reminderInterval <= 0 {
+			return 0, nil, fmt.Errorf("Invalid reminder_interval: %q", cfg.ReminderInterval)
+		}
+	}
+
+	escalations := make([][]escalation, len(cfg.Routes))
+	for i, route := range cfg.Routes {
+		for _, esc := range route.Escalations {
+			after, err := utils.ParseDuration(esc.After)
+			if err != nil || after <= 0 {
+				return 0, nil, fmt.Errorf("Invalid escalation after %q in route %d", esc.After, i+1)
+			}
+			escalations[i] = append(escalations[i], escalation{after, esc.Targets})
+		}
+	}
+	return reminderInterval, escalations, nil
+}
+
+func escalationKind(route, step int) string {
+	return fmt.Sprintf("escalation:%d:%d", route, step)
+}
+
+// SendFollowUps sends reminders and escalations for problems that remain. Problems that are acked,
+// silenced or flapping get no follow ups.
+func (m *Monitor) SendFollowUps() error {
+	return m.sendFollowUps(time.Now())
+}
+
+func (m *Monitor) sendFollowUps(now time.Time) error {
+	db, err := persist.Open(m.cfg.Database())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	overviews, err := db.GetCheckOverviews(false)
+	if err != nil {
+		return err
+	}
+	for _, o := range overviews {
+		if o.Result.Status == "good" || o.Ack != nil || o.Silenced {
+			continue
+		}
+		notifications, err := db.CurrentNotifications(o.CheckModel.ID)
+		if err != nil {
+			return err
+		}
+		if len(notifications) == 0 || notifications[0].Status == "good" || notifications[0].Status == "flapping" {
+			continue
+		}
+		// A broken target shouldn't stop the follow ups of other checks
+		err = m.followUp(db, o, notifications, now)
+		if err != nil {
+			ErrorLog.Printf("Follow up for check %d failed: %s", o.CheckModel.ID, err)
+			continue
+		}
+	}
+	return nil
+}
+
+// followUp sends the follow ups that are due for a problem given the notifications sent about it, oldest first
+func (m *Monitor) followUp(db *persist.DB, o persist.CheckOverview, notifications []persist.NotificationModel, now time.Time) error {
+	check := o.CheckModel
+	// We keep the notified status so that the follow ups don't count as a status change
+	status := notifications[0].Status
+	// Notifications made before we recorded timestamps count as made when the hub started
+	problemStart := maxTime(notifications[0].Timestamp, m.hubStartTime)
+
+	if m.reminderInterval > 0 {
+		lastSent := problemStart
+		for _, n := range notifications {
+			if n.Kind == "reminder" {
+				lastSent = maxTime(n.Timestamp, lastSent)
+			}
+		}
+		if now.Sub(lastSent) >= m.reminderInterval {
+			InfoLog.Printf("Reminder [%s] for check %d", status, check.ID)
+			// Reminders go to the targets of a new problem
+			err := send(Notification{Check: check, Result: o.Result, Kind: "reminder"}, m.route(check, "good", status))
+			if err != nil {
+				return err
+			}
+			err = db.AddFollowUpNotification(check.ID, status, "reminder")
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, i := range m.matchingRoutes(check, "good", status) {
+		for j, esc := range m.escalations[i] {
+			kind := escalationKind(i, j)
+			if now.Sub(problemStart) < esc.after || hasKind(notifications, kind) {
+				continue
+			}
+			InfoLog.Printf("Escalation [%s] for check %d to %v", status, check.ID, esc.targets)
+			err := send(Notification{Check: check, Result: o.Result, Kind: "escalation"}, m.notifiersByName(esc.targets))
+			if err != nil {
+				return err
+			}
+			err = db.AddFollowUpNotification(check.ID, status, kind)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func hasKind(notifications []persist.NotificationModel, kind string) bool {
+	for _, n := range notifications {
+		if n.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package monitor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/hubutil"
+	"github.com/rymdhund/whazza/internal/persist"
+)
+
+func notifiedTo(notifications []Notification, kind string) int {
+	n := 0
+	for _, notification := range notifications {
+		if notification.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+type failingNotifier struct{}
+
+func (failingNotifier) Notify(n Notification) error {
+	return errors.New("Broken")
+}
+
+func (failingNotifier) SendMessage(subject, body string) error {
+	return errors.New("Broken")
+}
+
+func TestRemindersWithBrokenTarget(t *testing.T) {
+	m, db, notifications := mkTestMonitor(t)
+	m.cfg.ReminderInterval = "4h"
+	broken := mkTestCheck(t, db, "db")
+	check := mkTestCheck(t, db, "web")
+	addResult(t, m, db, broken, "fail")
+	addResult(t, m, db, check, "fail")
+
+	// Reminders for the first check fail, the second check is still reminded
+	m.notifiers = append(m.notifiers, namedNotifier{"broken", failingNotifier{}})
+	m.cfg.Routes = []hubutil.RouteConfig{
+		{Namespace: "db", Targets: []string{"broken"}},
+		{Namespace: "web", Targets: []string{"test"}},
+	}
+	var err error
+	m.reminderInterval, m.escalations, err = parseFollowUps(m.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.sendFollowUps(time.Now().Add(5 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if notifiedTo(*notifications, "reminder") != 1 || (*notifications)[2].Check.ID != check.ID {
+		t.Fatalf("Expected a reminder for the second check, got %v", *notifications)
+	}
+}
+
+func TestReminders(t *testing.T) {
+	m, db, notifications := mkTestMonitor(t)
+	m.reminderInterval = 4 * time.Hour
+	check := mkTestCheck(t, db, "web")
+	addResult(t, m, db, check, "fail")
+
+	now := time.Now()
+	if err := m.sendFollowUps(now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if len(*notifications) != 1 {
+		t.Fatalf("Expected no reminder yet, got %v", *notifications)
+	}
+
+	if err := m.sendFollowUps(now.Add(5 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if len(*notifications) != 2 || (*notifications)[1].Kind != "reminder" || (*notifications)[1].Result.Status != "fail" {
+		t.Fatalf("Expected a reminder, got %v", *notifications)
+	}
+
+	// The reminder doesn't count as a status change
+	status, _ := db.LastNotification(check.ID)
+	if status != "fail" {
+		t.Fatalf("Expected fail, got %s", status)
+	}
+
+	// Reminders are not sent for acked checks
+	if err := db.AckCheck(check, "fail", persist.AckModel{User: "alice", Timestamp: now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.sendFollowUps(now.Add(10 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if len(*notifications) != 2 {
+		t.Fatalf("Expected no reminder for acked check, got %v", *notifications)
+	}
+}
+
+func TestEscalations(t *testing.T) {
+	m, db, _ := mkTestMonitor(t)
+	teamA := []Notification{}
+	teamB := []Notification{}
+	m.notifiers = []namedNotifier{{"a", recordingNotifier{&teamA}}, {"b", recordingNotifier{&teamB}}}
+	m.cfg.Routes = []hubutil.RouteConfig{
+		{Targets: []string{"a"}, Escalations: []hubutil.EscalationConfig{{After: "30m", Targets: []string{"b"}}}},
+	}
+	var err error
+	m.reminderInterval, m.escalations, err = parseFollowUps(m.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := mkTestCheck(t, db, "web")
+	addResult(t, m, db, check, "fail")
+	if len(teamA) != 1 || len(teamB) != 0 {
+		t.Fatalf("Expected only team a to be notified, got %v %v", teamA, teamB)
+	}
+
+	now := time.Now()
+	if err := m.sendFollowUps(now.Add(10 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if len(teamB) != 0 {
+		t.Fatalf("Expected no escalation yet, got %v", teamB)
+	}
+	for i := 0; i < 2; i++ {
+		if err := m.sendFollowUps(now.Add(40 * time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(teamB) != 1 || notifiedTo(teamB, "escalation") != 1 || len(teamA) != 1 {
+		t.Fatalf("Expected one escalation to team b, got %v %v", teamA, teamB)
+	}
+
+	// A new problem escalates again
+	addResult(t, m, db, check, "good")
+	addResult(t, m, db, check, "fail")
+	if err := m.sendFollowUps(time.Now().Add(40 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if len(teamB) != 2 {
+		t.Fatalf("Expected a second escalation, got %v", teamB)
+	}
+}
+
+func TestParseFollowUps(t *testing.T) {
+	invalid := []hubutil.HubConfig{
+		{ReminderInterval: "often"},
+		{Routes: []hubutil.RouteConfig{{Targets: []string{"a"}, Escalations: []hubutil.EscalationConfig{{After: "", Targets: []string{"a"}}}}}},
+	}
+	for _, cfg := range invalid {
+		if _, _, err := parseFollowUps(cfg); err == nil {
+			t.Errorf("Expected error for %+v", cfg)
+		}
+	}
+}
@@ -1,9 +1,7 @@
 package monitor
 
 import (
-	"errors"
 	"fmt"
-	"net/smtp"
 	"time"
 
 	"github.com/rymdhund/whazza/internal/base"
@@ -14,47 +12,78 @@ import (
 
 // Monitor the checks for timeouts
 type Monitor struct {
-	cfg          hubutil.HubConfig
-	hubStartTime time.Time
+	cfg              hubutil.HubConfig
+	hubStartTime     time.Time
+	notifiers        []namedNotifier
+	reminderInterval time.Duration
+	// The escalation steps of each route
+	escalations [][]escalation
+	digests     []digest
 }
 
-type Mailer struct {
-	host     string
-	port     int
-	user     string
-	password string
-	from     string
-}
-
-func New(cfg hubutil.HubConfig, hubStartTime time.Time) *Monitor {
-	return &Monitor{cfg, hubStartTime}
-}
-
-func (m *Monitor) mkMailer() (Mailer, error) {
-	if m.cfg.SMTPHost == "" {
-		return Mailer{}, errors.New("No smtphost configured")
+func New(cfg hubutil.HubConfig, hubStartTime time.Time) (*Monitor, error) {
+	notifiers := []namedNotifier{}
+	if cfg.NotifyEmail != "" {
+		mailer, err := mkMailer(cfg, cfg.NotifyEmail)
+		if err != nil && usesTarget(cfg, "email") {
+			return nil, fmt.Errorf("Can't notify email: %w", err)
+		} else if err != nil {
+			WarningLog.Printf("Not sending mail since: %s", err)
+		} else {
+			notifiers = append(notifiers, namedNotifier{"email", mailer})
+		}
 	}
-	if m.cfg.SMTPPort == 0 {
-		return Mailer{}, errors.New("No smtpport configured")
+	for _, nc := range cfg.Notifiers {
+		if findNotifier(notifiers, nc.Name) != nil {
+			return nil, fmt.Errorf("Duplicate notifier name %q", nc.Name)
+		}
+		n, err := NewNotifier(nc, cfg)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, namedNotifier{nc.Name, n})
 	}
-	if m.cfg.SMTPUser == "" {
-		return Mailer{}, errors.New("No smtpuser configured")
+	if err := validateRoutes(cfg.Routes, notifiers); err != nil {
+		return nil, err
 	}
-	if m.cfg.SMTPPassword == "" {
-		return Mailer{}, errors.New("No smtppassword configured")
+	reminderInterval, escalations, err := parseFollowUps(cfg)
+	if err != nil {
+		return nil, err
 	}
-	if m.cfg.SMTPFrom == "" {
-		return Mailer{}, errors.New("No smtpfrom configured")
+	digests, err := parseDigests(cfg.Digests, notifiers)
+	if err != nil {
+		return nil, err
 	}
-	return Mailer{
-		host:     m.cfg.SMTPHost,
-		port:     m.cfg.SMTPPort,
-		user:     m.cfg.SMTPUser,
-		password: m.cfg.SMTPPassword,
-		from:     m.cfg.SMTPFrom,
+	return &Monitor{
+		cfg:              cfg,
+		hubStartTime:     hubStartTime,
+		notifiers:        notifiers,
+		reminderInterval: reminderInterval,
+		escalations:      escalations,
+		digests:          digests,
 	}, nil
 }
 
+// usesTarget returns true if a route, escalation or digest sends to the named notifier
+func usesTarget(cfg hubutil.HubConfig, name string) bool {
+	targets := []string{}
+	for _, route := range cfg.Routes {
+		targets = append(targets, route.Targets...)
+		for _, esc := range route.Escalations {
+			targets = append(targets, esc.Targets...)
+		}
+	}
+	for _, dc := range cfg.Digests {
+		targets = append(targets, dc.Targets...)
+	}
+	for _, target := range targets {
+		if target == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *Monitor) CheckForExpired() error {
 	db, err := persist.Open(m.cfg.Database())
 	if err != nil {
@@ -62,19 +91,33 @@ func (m *Monitor) CheckForExpired() error {
 	}
 	defer db.Close()
 
-	expChecks, err := getExpiredChecks(db, m.hubStartTime)
+	expired, err := getExpiredChecks(db, m.hubStartTime)
 	if err != nil {
 		return err
 	}
-	for _, check := range expChecks {
+	for _, o := range expired {
+		check := o.CheckModel
+		err := db.AddStatusEvent(check, "expired", "", time.Now())
+		if err != nil {
+			return err
+		}
+		// Silenced expiries are not recorded, so they are notified when the silence ends, and acked problems
+		// are not notified until they recover. Until then we don't try again every time.
+		if o.Silenced || o.Ack != nil {
+			continue
+		}
 
 		lastStatus, err := db.LastNotification(check.ID)
 		if err != nil {
 			return err
 		}
 
-		if lastStatus != "expired" {
-			err := m.notify(db, check, base.ExpiredResult())
+		if lastStatus == "" {
+			lastStatus = "good"
+		}
+
+		if lastStatus != "expired" && m.cfg.ShouldNotify("expired") {
+			err := m.notify(db, check, lastStatus, base.ExpiredResult())
 			if err != nil {
 				return err
 			}
@@ -101,52 +144,87 @@ func (m *Monitor) HandleResult(check persist.CheckModel, res persist.ResultModel
 		oldStatus = "good"
 	}
 
-	if res.Status != oldStatus {
-		err := m.notify(db, check, res.Result)
-		if err != nil {
+	recent, err := db.GetRecentResults(check.ID, historyLen(check.Check))
+	if err != nil {
+		return err
+	}
+
+	next, ok := m.nextNotification(check.Check, oldStatus, recent)
+	if !ok {
+		return nil
+	}
+	if m.cfg.ShouldNotify(next.Status) {
+		return m.notify(db, check, oldStatus, next)
+	} else if next.Status == "flapping" {
+		// We still need to remember that the check is flapping to suppress other notifications,
+		// unless it is acked since that would clear the ack
+		ack, err := db.GetAck(check.ID)
+		if err != nil || ack != nil {
 			return err
 		}
+		return db.AddNotification(check.ID, next.Status)
 	}
 	return nil
 }
 
-func (m *Monitor) notify(db *persist.DB, check persist.CheckModel, res base.Result) error {
-	InfoLog.Printf("Notification [%s] %+v", res.Status, check)
-	subj := fmt.Sprintf("%s %s", check.Check.Title(), res.Status)
-	body := fmt.Sprintf("%+v\n[%s] - %s", check, res.Status, res.Msg)
+func (m *Monitor) notify(db *persist.DB, check persist.CheckModel, oldStatus string, res base.Result) error {
+	silences, err := db.ActiveSilences(check, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(silences) > 0 {
+		InfoLog.Printf("Notification [%s -> %s] for check %d silenced by silence %d", oldStatus, res.Status, check.ID, silences[0].ID)
+		// We record recoveries but not problems, so that problems that remain when the silence ends are notified then
+		if res.Status == "good" {
+			return db.AddNotification(check.ID, res.Status)
+		}
+		return nil
+	}
 
-	if m.cfg.NotifyEmail != "" {
-		mailer, err := m.mkMailer()
+	if res.Status != "good" {
+		ack, err := db.GetAck(check.ID)
 		if err != nil {
-			WarningLog.Printf("Not sending mail since: %s", err)
-		} else {
-			err := mailer.sendMail(m.cfg.NotifyEmail, subj, body)
-			if err != nil {
-				return err
-			}
+			return err
+		}
+		if ack != nil {
+			InfoLog.Printf("Notification [%s -> %s] for check %d suppressed, acked by %s", oldStatus, res.Status, check.ID, ack.User)
+			return nil
 		}
 	}
-	err := db.AddNotification(check.ID, res.Status)
-	return err
-}
-
-func (m Mailer) sendMail(to, subject, body string) error {
-	DebugLog.Printf("Sending notification email to %s", to)
-	auth := smtp.PlainAuth("", m.user, m.password, m.host)
-	addr := fmt.Sprintf("%s:%d", m.host, m.port)
-
-	msg := fmt.Sprintf("To: %s\r\n", to)
-	msg += fmt.Sprintf("Subject: %s\r\n", subject)
-	msg += "\r\n"
-	msg += fmt.Sprintf("%s\r\n", body)
 
-	err := smtp.SendMail(addr, auth, m.from, []string{to}, []byte(msg))
+	InfoLog.Printf("Notification [%s -> %s] %+v", oldStatus, res.Status, check)
+	err = send(Notification{Check: check, Result: res}, m.route(check, oldStatus, res.Status))
 	if err != nil {
-		return fmt.Errorf("sendMail: failed with %w", err)
+		return err
+	}
+	return db.AddNotification(check.ID, res.Status)
+}
+
+// sendAll sends something to each notifier. We only fail if no notifier succeeded, so that we don't spam
+// the working ones when retrying.
+func sendAll(notifiers []namedNotifier, send func(Notifier) error) error {
+	var lastErr error
+	sent := 0
+	for _, notifier := range notifiers {
+		err := send(notifier)
+		if err != nil {
+			ErrorLog.Printf("Notifier %s failed: %s", notifier.name, err)
+			lastErr = err
+		} else {
+			sent++
+		}
+	}
+	if sent == 0 && lastErr != nil {
+		return lastErr
 	}
 	return nil
 }
 
+// send sends the notification to the notifiers
+func send(n Notification, notifiers []namedNotifier) error {
+	return sendAll(notifiers, func(notifier Notifier) error { return notifier.Notify(n) })
+}
+
 func maxTime(t1 time.Time, t2 time.Time) time.Time {
 	if t1.After(t2) {
 		return t1
@@ -155,20 +233,20 @@ func maxTime(t1 time.Time, t2 time.Time) time.Time {
 	}
 }
 
-func getExpiredChecks(db *persist.DB, hubStart time.Time) ([]persist.CheckModel, error) {
-	overviews, err := db.GetCheckOverviews()
+func getExpiredChecks(db *persist.DB, hubStart time.Time) ([]persist.CheckOverview, error) {
+	overviews, err := db.GetCheckOverviews(false)
 	if err != nil {
 		return nil, err
 	}
 
-	expired := []persist.CheckModel{}
+	expired := []persist.CheckOverview{}
 	for _, ov := range overviews {
 		if ov.Result.Status == "expired" {
 			// If the server is newly started we give checks a chance to report in
 			// Do this by pretending we got a result right before the hub started
 			t := maxTime(ov.LastReceived.Timestamp, hubStart)
 			if ov.CheckModel.Check.IsExpired(t, time.Now()) {
-				expired = append(expired, ov.CheckModel)
+				expired = append(expired, ov)
 			}
 		}
 	}
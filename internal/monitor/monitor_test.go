@@ -0,0 +1,246 @@
+package monitor
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/base"
+	"github.com/rymdhund/whazza/internal/chk"
+	"github.com/rymdhund/whazza/internal/hubutil"
+	. "github.com/rymdhund/whazza/internal/logging"
+	"github.com/rymdhund/whazza/internal/persist"
+)
+
+func TestMain(m *testing.M) {
+	DebugLog = log.New(ioutil.Discard, "", 0)
+	InfoLog = log.New(ioutil.Discard, "", 0)
+	WarningLog = log.New(ioutil.Discard, "", 0)
+	ErrorLog = log.New(ioutil.Discard, "", 0)
+	os.Exit(m.Run())
+}
+
+type recordingNotifier struct {
+	notifications *[]Notification
+}
+
+func (r recordingNotifier) Notify(n Notification) error {
+	*r.notifications = append(*r.notifications, n)
+	return nil
+}
+
+// SendMessage records messages as notifications of the kind "message" with the subject and body as message
+func (r recordingNotifier) SendMessage(subject, body string) error {
+	*r.notifications = append(*r.notifications, Notification{Kind: "message", Result: base.Result{Msg: subject + "\n" + body}})
+	return nil
+}
+
+// mkTestMonitor creates a monitor with a fresh database and a notifier that records notifications
+func mkTestMonitor(t *testing.T) (*Monitor, *persist.DB, *[]Notification) {
+	cfg := hubutil.HubConfig{DataDir: t.TempDir(), DisableFlapDetection: true, NotifyStatuses: []string{"fail"}}
+	db, err := persist.Open(cfg.Database())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Init(); err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(cfg, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifications := []Notification{}
+	m.notifiers = []namedNotifier{{"test", recordingNotifier{&notifications}}}
+	return m, db, &notifications
+}
+
+func mkTestCheck(t *testing.T, db *persist.DB, namespace string) persist.CheckModel {
+	if err := db.SaveAgent("agent", ""); err != nil {
+		t.Fatal(err)
+	}
+	agents, err := db.GetAgents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := agents[0]
+	check, err := chk.New("http-up", namespace, 60, []byte(`{"host": "example.com"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm, err := db.RegisterCheck(agent, check)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cm
+}
+
+func addResult(t *testing.T, m *Monitor, db *persist.DB, check persist.CheckModel, status string) {
+	res, err := db.AddResult(check.Agent, check, base.Result{Status: status, Timestamp: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.HandleResult(check, res); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewWithoutMailer(t *testing.T) {
+	// Mail is optional unless something sends to it
+	cfg := hubutil.HubConfig{DataDir: t.TempDir(), NotifyEmail: "ops@example.com"}
+	if _, err := New(cfg, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Routes = []hubutil.RouteConfig{{Targets: []string{"email"}}}
+	_, err := New(cfg, time.Now())
+	if err == nil || !strings.Contains(err.Error(), "No smtphost configured") {
+		t.Fatalf("Expected the mailer error, got %v", err)
+	}
+}
+
+func TestHandleResultSilenced(t *testing.T) {
+	m, db, notifications := mkTestMonitor(t)
+	check := mkTestCheck(t, db, "db:postgres")
+
+	silence, err := db.AddSilence(persist.SilenceModel{Namespace: "db:*", Start: time.Now().Add(-time.Minute), End: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addResult(t, m, db, check, "fail")
+	if len(*notifications) != 0 {
+		t.Fatalf("Expected no notifications while silenced, got %v", *notifications)
+	}
+
+	// When the silence ends we notify if the check is still failing
+	if err := db.ExpireSilence(silence.ID, "test", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	addResult(t, m, db, check, "fail")
+	if len(*notifications) != 1 || (*notifications)[0].Result.Status != "fail" {
+		t.Fatalf("Expected a fail notification, got %v", *notifications)
+	}
+}
+
+func TestHandleResultRecoveryWhileSilenced(t *testing.T) {
+	m, db, notifications := mkTestMonitor(t)
+	check := mkTestCheck(t, db, "db:postgres")
+
+	addResult(t, m, db, check, "fail")
+	silence, err := db.AddSilence(persist.SilenceModel{CheckID: check.ID, Start: time.Now().Add(-time.Minute), End: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addResult(t, m, db, check, "good")
+	if err := db.ExpireSilence(silence.ID, "test", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new failure after the silence must be notified
+	addResult(t, m, db, check, "fail")
+	if len(*notifications) != 2 {
+		t.Fatalf("Expected two fail notifications, got %v", *notifications)
+	}
+}
+
+// expireCheck makes the check expired by giving it an old result
+func expireCheck(t *testing.T, m *Monitor, db *persist.DB, check persist.CheckModel, status string) {
+	m.hubStartTime = time.Now().Add(-24 * time.Hour)
+	_, err := db.AddResult(check.Agent, check, base.Result{Status: status, Timestamp: time.Now().Add(-2 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// captureInfoLog returns the info log written while f runs
+func captureInfoLog(f func()) string {
+	var buf bytes.Buffer
+	old := InfoLog
+	InfoLog = log.New(&buf, "", 0)
+	defer func() { InfoLog = old }()
+	f()
+	return buf.String()
+}
+
+func TestCheckForExpiredSilenced(t *testing.T) {
+	m, db, notifications := mkTestMonitor(t)
+	m.cfg.NotifyStatuses = []string{"fail", "expired"}
+	check := mkTestCheck(t, db, "web")
+	expireCheck(t, m, db, check, "good")
+
+	silence, err := db.AddSilence(persist.SilenceModel{CheckID: check.ID, Start: time.Now().Add(-time.Minute), End: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logged := captureInfoLog(func() {
+		for i := 0; i < 3; i++ {
+			if err := m.CheckForExpired(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	if len(*notifications) != 0 || strings.Contains(logged, "Notification") {
+		t.Fatalf("Expected no notification attempts while silenced, got %v\n%s", *notifications, logged)
+	}
+
+	// When the silence ends the expiry is notified
+	if err := db.ExpireSilence(silence.ID, "test", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.CheckForExpired(); err != nil {
+		t.Fatal(err)
+	}
+	if len(*notifications) != 1 || (*notifications)[0].Result.Status != "expired" {
+		t.Fatalf("Expected an expired notification, got %v", *notifications)
+	}
+}
+
+func TestCheckForExpiredAcked(t *testing.T) {
+	m, db, notifications := mkTestMonitor(t)
+	m.cfg.NotifyStatuses = []string{"fail", "expired"}
+	check := mkTestCheck(t, db, "web")
+	expireCheck(t, m, db, check, "fail")
+	err := db.AckCheck(check, "fail", persist.AckModel{User: "alice", Timestamp: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logged := captureInfoLog(func() {
+		for i := 0; i < 3; i++ {
+			if err := m.CheckForExpired(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	if len(*notifications) != 0 || strings.Contains(logged, "Notification") {
+		t.Fatalf("Expected no notification attempts while acked, got %v\n%s", *notifications, logged)
+	}
+}
+
+func TestHandleResultAcked(t *testing.T) {
+	m, db, notifications := mkTestMonitor(t)
+	m.cfg.NotifyStatuses = []string{"warning", "fail"}
+	check := mkTestCheck(t, db, "web")
+
+	addResult(t, m, db, check, "warning")
+	err := db.AckCheck(check, "warning", persist.AckModel{User: "alice", Timestamp: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Worse status is suppressed while acked
+	addResult(t, m, db, check, "fail")
+	if len(*notifications) != 1 {
+		t.Fatalf("Expected only the first notification, got %v", *notifications)
+	}
+
+	// Recovery is notified and clears the ack
+	addResult(t, m, db, check, "good")
+	addResult(t, m, db, check, "fail")
+	if len(*notifications) != 3 || (*notifications)[1].Result.Status != "good" || (*notifications)[2].Result.Status != "fail" {
+		t.Fatalf("Expected recovery and new failure to be notified, got %v", *notifications)
+	}
+}
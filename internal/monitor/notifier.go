@@ -0,0 +1,134 @@
+package monitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/base"
+	"github.com/rymdhund/whazza/internal/hubutil"
+	"github.com/rymdhund/whazza/internal/persist"
+)
+
+// Notification is a message about a check changing status
+type Notification struct {
+	Check  persist.CheckModel
+	Result base.Result
+	// Kind is "reminder" or "escalation" for follow ups on problems that remain, empty otherwise
+	Kind string
+}
+
+// Notifier sends notifications to some target, eg an email address or a chat
+type Notifier interface {
+	Notify(n Notification) error
+	// SendMessage sends a message that is not about a single check, eg a digest
+	SendMessage(subject, body string) error
+}
+
+type namedNotifier struct {
+	name string
+	Notifier
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+func (n Notification) Subject() string {
+	subject := fmt.Sprintf("%s %s", n.Check.Check.Title(), n.Result.Status)
+	switch n.Kind {
+	case "reminder":
+		return "Reminder: " + subject
+	case "escalation":
+		return "Escalation: " + subject
+	}
+	return subject
+}
+
+func (n Notification) Body() string {
+	body := fmt.Sprintf("[%s] %s is %s\nAgent: %s\nCheck id: %d",
+		n.Check.Check.Namespace,
+		n.Check.Check.Title(),
+		n.Result.Status,
+		n.Check.Agent.Name,
+		n.Check.ID,
+	)
+	if n.Result.Msg != "" {
+		body += fmt.Sprintf("\nMessage: %s", n.Result.Msg)
+	}
+	return body
+}
+
+// NewNotifier creates a notifier from its configuration
+func NewNotifier(nc hubutil.NotifierConfig, cfg hubutil.HubConfig) (Notifier, error) {
+	switch nc.Type {
+	case "email":
+		if nc.To == "" {
+			return nil, fmt.Errorf("No to address for email notifier %s", nc.Name)
+		}
+		return mkMailer(cfg, nc.To)
+	case "webhook":
+		if nc.URL == "" {
+			return nil, fmt.Errorf("No url for webhook notifier %s", nc.Name)
+		}
+		return WebhookNotifier{url: nc.URL, headers: nc.Headers}, nil
+	case "slack":
+		if nc.URL == "" {
+			return nil, fmt.Errorf("No url for slack notifier %s", nc.Name)
+		}
+		return SlackNotifier{url: nc.URL}, nil
+	case "ntfy":
+		if nc.URL == "" {
+			return nil, fmt.Errorf("No url for ntfy notifier %s", nc.Name)
+		}
+		return NtfyNotifier{url: nc.URL, token: nc.Token}, nil
+	case "gotify":
+		if nc.URL == "" || nc.Token == "" {
+			return nil, fmt.Errorf("Gotify notifier %s needs url and token", nc.Name)
+		}
+		return GotifyNotifier{url: nc.URL, token: nc.Token}, nil
+	case "telegram":
+		if nc.Token == "" || nc.ChatID == "" {
+			return nil, fmt.Errorf("Telegram notifier %s needs token and chat_id", nc.Name)
+		}
+		apiURL := nc.URL
+		if apiURL == "" {
+			apiURL = "https://api.telegram.org"
+		}
+		return TelegramNotifier{apiURL: apiURL, token: nc.Token, chatID: nc.ChatID}, nil
+	default:
+		return nil, fmt.Errorf("Unknown notifier type %q for %s", nc.Type, nc.Name)
+	}
+}
+
+// doRequest sends the request and fails on non 2xx responses
+func doRequest(req *http.Request) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Unexpected status %d: %s", resp.StatusCode, body)
+	}
+	return nil
+}
+
+func postJson(url string, headers map[string]string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return doRequest(req)
+}
@@ -0,0 +1,71 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"net/smtp"
+
+	"github.com/rymdhund/whazza/internal/hubutil"
+	. "github.com/rymdhund/whazza/internal/logging"
+)
+
+// Mailer sends notifications by email using the smtp settings in the hub config
+type Mailer struct {
+	host     string
+	port     int
+	user     string
+	password string
+	from     string
+	to       string
+}
+
+func mkMailer(cfg hubutil.HubConfig, to string) (Mailer, error) {
+	if cfg.SMTPHost == "" {
+		return Mailer{}, errors.New("No smtphost configured")
+	}
+	if cfg.SMTPPort == 0 {
+		return Mailer{}, errors.New("No smtpport configured")
+	}
+	if cfg.SMTPUser == "" {
+		return Mailer{}, errors.New("No smtpuser configured")
+	}
+	if cfg.SMTPPassword == "" {
+		return Mailer{}, errors.New("No smtppassword configured")
+	}
+	if cfg.SMTPFrom == "" {
+		return Mailer{}, errors.New("No smtpfrom configured")
+	}
+	return Mailer{
+		host:     cfg.SMTPHost,
+		port:     cfg.SMTPPort,
+		user:     cfg.SMTPUser,
+		password: cfg.SMTPPassword,
+		from:     cfg.SMTPFrom,
+		to:       to,
+	}, nil
+}
+
+func (m Mailer) Notify(n Notification) error {
+	return m.sendMail(m.to, n.Subject(), n.Body())
+}
+
+func (m Mailer) SendMessage(subject, body string) error {
+	return m.sendMail(m.to, subject, body)
+}
+
+func (m Mailer) sendMail(to, subject, body string) error {
+	DebugLog.Printf("Sending notification email to %s", to)
+	auth := smtp.PlainAuth("", m.user, m.password, m.host)
+	addr := fmt.Sprintf("%s:%d", m.host, m.port)
+
+	msg := fmt.Sprintf("To: %s\r\n", to)
+	msg += fmt.Sprintf("Subject: %s\r\n", subject)
+	msg += "\r\n"
+	msg += fmt.Sprintf("%s\r\n", body)
+
+	err := smtp.SendMail(addr, auth, m.from, []string{to}, []byte(msg))
+	if err != nil {
+		return fmt.Errorf("sendMail: failed with %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// WebhookNotifier posts the notification as json to a url
+type WebhookNotifier struct {
+	url     string
+	headers map[string]string
+}
+
+type webhookPayload struct {
+	CheckID   int       `json:"check_id"`
+	Agent     string    `json:"agent"`
+	Namespace string    `json:"namespace"`
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	Status    string    `json:"status"`
+	Kind      string    `json:"kind,omitempty"`
+	Msg       string    `json:"msg"`
+	Timestamp time.Time `json:"timestamp"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body"`
+}
+
+func (w WebhookNotifier) Notify(n Notification) error {
+	return postJson(w.url, w.headers, webhookPayload{
+		CheckID:   n.Check.ID,
+		Agent:     n.Check.Agent.Name,
+		Namespace: n.Check.Check.Namespace,
+		Type:      n.Check.Check.Type,
+		Title:     n.Check.Check.Title(),
+		Status:    n.Result.Status,
+		Kind:      n.Kind,
+		Msg:       n.Result.Msg,
+		Timestamp: n.Result.Timestamp,
+		Subject:   n.Subject(),
+		Body:      n.Body(),
+	})
+}
+
+func (w WebhookNotifier) SendMessage(subject, body string) error {
+	return postJson(w.url, w.headers, map[string]string{"subject": subject, "body": body})
+}
+
+// SlackNotifier posts to a Slack or Mattermost incoming webhook
+type SlackNotifier struct {
+	url string
+}
+
+func (s SlackNotifier) Notify(n Notification) error {
+	text := fmt.Sprintf("%s *%s*\n%s", statusEmoji(n.Result.Status), n.Subject(), n.Body())
+	return postJson(s.url, nil, map[string]string{"text": text})
+}
+
+func (s SlackNotifier) SendMessage(subject, body string) error {
+	text := fmt.Sprintf("*%s*\n```%s```", subject, body)
+	return postJson(s.url, nil, map[string]string{"text": text})
+}
+
+// NtfyNotifier publishes to a ntfy topic, the url includes the topic
+type NtfyNotifier struct {
+	url   string
+	token string
+}
+
+func (s NtfyNotifier) Notify(n Notification) error {
+	return s.publish(n.Subject(), n.Body(), priority(n.Result.Status), n.Result.Status)
+}
+
+func (s NtfyNotifier) SendMessage(subject, body string) error {
+	return s.publish(subject, body, 3, "")
+}
+
+func (s NtfyNotifier) publish(title, body string, prio int, tags string) error {
+	req, err := http.NewRequest("POST", s.url, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", title)
+	req.Header.Set("Priority", fmt.Sprint(prio))
+	if tags != "" {
+		req.Header.Set("Tags", tags)
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	return doRequest(req)
+}
+
+// GotifyNotifier sends messages to a Gotify server using an application token
+type GotifyNotifier struct {
+	url   string
+	token string
+}
+
+func (g GotifyNotifier) Notify(n Notification) error {
+	return g.send(n.Subject(), n.Body(), priority(n.Result.Status)*2)
+}
+
+func (g GotifyNotifier) SendMessage(subject, body string) error {
+	return g.send(subject, body, 4)
+}
+
+func (g GotifyNotifier) send(title, message string, prio int) error {
+	url := strings.TrimSuffix(g.url, "/") + "/message"
+	payload := map[string]interface{}{
+		"title":    title,
+		"message":  message,
+		"priority": prio,
+	}
+	return postJson(url, map[string]string{"X-Gotify-Key": g.token}, payload)
+}
+
+// TelegramNotifier sends messages through the Telegram bot api
+type TelegramNotifier struct {
+	apiURL string
+	token  string
+	chatID string
+}
+
+func (t TelegramNotifier) Notify(n Notification) error {
+	return t.SendMessage(n.Subject(), n.Body())
+}
+
+func (t TelegramNotifier) SendMessage(subject, body string) error {
+	endpoint := fmt.Sprintf("%s/bot%s/sendMessage", strings.TrimSuffix(t.apiURL, "/"), t.token)
+	payload := map[string]string{
+		"chat_id": t.chatID,
+		"text":    fmt.Sprintf("%s\n%s", subject, body),
+	}
+	err := postJson(endpoint, nil, payload)
+	// The url contains the bot token so we leave it out of errors, which are logged
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%s telegram api: %w", urlErr.Op, urlErr.Err)
+	}
+	return err
+}
+
+// priority maps a status to a ntfy style priority between 1 (min) and 5 (max)
+func priority(status string) int {
+	switch status {
+	case "fail", "expired":
+		return 5
+	case "warning", "error":
+		return 4
+	default:
+		return 3
+	}
+}
+
+// statusEmoji returns an emoji shortcode as understood by Slack and Mattermost
+func statusEmoji(status string) string {
+	switch status {
+	case "good":
+		return ":white_check_mark:"
+	case "warning":
+		return ":warning:"
+	default:
+		return ":x:"
+	}
+}
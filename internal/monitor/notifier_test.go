@@ -0,0 +1,191 @@
+package monitor
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/base"
+	"github.com/rymdhund/whazza/internal/chk"
+	"github.com/rymdhund/whazza/internal/hubutil"
+	"github.com/rymdhund/whazza/internal/persist"
+)
+
+type capturedRequest struct {
+	path    string
+	headers http.Header
+	body    string
+}
+
+func startCaptureServer(t *testing.T, status int) (*httptest.Server, chan capturedRequest) {
+	requests := make(chan capturedRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- capturedRequest{r.URL.Path, r.Header, string(body)}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+func testNotification(t *testing.T) Notification {
+	check, err := chk.New("http-up", "web", 60, []byte(`{"host": "example.com"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Notification{
+		Check: persist.CheckModel{ID: 7, Check: check, Agent: persist.AgentModel{Name: "agent1"}},
+		Result: base.Result{
+			Status:    "fail",
+			Msg:       "Connection refused",
+			Timestamp: time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	srv, requests := startCaptureServer(t, 200)
+	n, err := NewNotifier(hubutil.NotifierConfig{Name: "hook", Type: "webhook", URL: srv.URL + "/hook", Headers: map[string]string{"X-Secret": "s3"}}, hubutil.HubConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(testNotification(t)); err != nil {
+		t.Fatal(err)
+	}
+	req := <-requests
+	if req.path != "/hook" || req.headers.Get("X-Secret") != "s3" {
+		t.Fatalf("Unexpected request %+v", req)
+	}
+	var payload webhookPayload
+	if err := json.Unmarshal([]byte(req.body), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.CheckID != 7 || payload.Status != "fail" || payload.Agent != "agent1" || payload.Namespace != "web" || payload.Msg != "Connection refused" {
+		t.Fatalf("Unexpected payload %+v", payload)
+	}
+}
+
+func TestSlackNotifier(t *testing.T) {
+	srv, requests := startCaptureServer(t, 200)
+	n, err := NewNotifier(hubutil.NotifierConfig{Name: "slack", Type: "slack", URL: srv.URL}, hubutil.HubConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(testNotification(t)); err != nil {
+		t.Fatal(err)
+	}
+	req := <-requests
+	var payload map[string]string
+	if err := json.Unmarshal([]byte(req.body), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(payload["text"], "http:example.com fail") {
+		t.Fatalf("Unexpected text %q", payload["text"])
+	}
+}
+
+func TestNtfyNotifier(t *testing.T) {
+	srv, requests := startCaptureServer(t, 200)
+	n, err := NewNotifier(hubutil.NotifierConfig{Name: "ntfy", Type: "ntfy", URL: srv.URL + "/alerts", Token: "tk"}, hubutil.HubConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(testNotification(t)); err != nil {
+		t.Fatal(err)
+	}
+	req := <-requests
+	if req.path != "/alerts" || req.headers.Get("Priority") != "5" || req.headers.Get("Authorization") != "Bearer tk" {
+		t.Fatalf("Unexpected request %+v", req)
+	}
+	if !strings.Contains(req.body, "Message: Connection refused") {
+		t.Fatalf("Unexpected body %q", req.body)
+	}
+}
+
+func TestGotifyNotifier(t *testing.T) {
+	srv, requests := startCaptureServer(t, 200)
+	n, err := NewNotifier(hubutil.NotifierConfig{Name: "gotify", Type: "gotify", URL: srv.URL + "/", Token: "app"}, hubutil.HubConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(testNotification(t)); err != nil {
+		t.Fatal(err)
+	}
+	req := <-requests
+	if req.path != "/message" || req.headers.Get("X-Gotify-Key") != "app" {
+		t.Fatalf("Unexpected request %+v", req)
+	}
+}
+
+func TestTelegramNotifier(t *testing.T) {
+	srv, requests := startCaptureServer(t, 200)
+	n, err := NewNotifier(hubutil.NotifierConfig{Name: "tg", Type: "telegram", URL: srv.URL, Token: "123:abc", ChatID: "-42"}, hubutil.HubConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(testNotification(t)); err != nil {
+		t.Fatal(err)
+	}
+	req := <-requests
+	if req.path != "/bot123:abc/sendMessage" {
+		t.Fatalf("Unexpected path %s", req.path)
+	}
+	var payload map[string]string
+	if err := json.Unmarshal([]byte(req.body), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload["chat_id"] != "-42" {
+		t.Fatalf("Unexpected payload %+v", payload)
+	}
+}
+
+func TestTelegramNotifierHidesToken(t *testing.T) {
+	srv, _ := startCaptureServer(t, 200)
+	srv.Close()
+	n, err := NewNotifier(hubutil.NotifierConfig{Name: "tg", Type: "telegram", URL: srv.URL, Token: "123:abc", ChatID: "-42"}, hubutil.HubConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.Notify(testNotification(t))
+	if err == nil || strings.Contains(err.Error(), "123:abc") {
+		t.Fatalf("Expected an error without the token, got %v", err)
+	}
+}
+
+func TestNotifierErrorStatus(t *testing.T) {
+	srv, _ := startCaptureServer(t, 500)
+	n, err := NewNotifier(hubutil.NotifierConfig{Name: "hook", Type: "webhook", URL: srv.URL}, hubutil.HubConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(testNotification(t)); err == nil {
+		t.Fatal("Expected error on status 500")
+	}
+}
+
+func TestNewNotifierValidation(t *testing.T) {
+	invalid := []hubutil.NotifierConfig{
+		{Name: "a", Type: "webhook"},
+		{Name: "b", Type: "gotify", URL: "http://localhost"},
+		{Name: "c", Type: "telegram", Token: "t"},
+		{Name: "d", Type: "email", To: "a@example.com"},
+		{Name: "e", Type: "pigeon"},
+	}
+	for _, nc := range invalid {
+		if _, err := NewNotifier(nc, hubutil.HubConfig{}); err == nil {
+			t.Errorf("Expected error for %+v", nc)
+		}
+	}
+
+	cfg := hubutil.HubConfig{Notifiers: []hubutil.NotifierConfig{
+		{Name: "x", Type: "slack", URL: "http://localhost"},
+		{Name: "x", Type: "slack", URL: "http://localhost"},
+	}}
+	if _, err := New(cfg, time.Now()); err == nil {
+		t.Fatal("Expected error for duplicate notifier names")
+	}
+}
@@ -0,0 +1,113 @@
+package monitor
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/rymdhund/whazza/internal/hubutil"
+	"github.com/rymdhund/whazza/internal/persist"
+)
+
+func validateRoutes(routes []hubutil.RouteConfig, notifiers []namedNotifier) error {
+	for i, route := range routes {
+		if _, err := path.Match(route.Namespace, ""); err != nil {
+			return fmt.Errorf("Invalid namespace pattern in route %d: %w", i+1, err)
+		}
+		if _, err := path.Match(route.Agent, ""); err != nil {
+			return fmt.Errorf("Invalid agent pattern in route %d: %w", i+1, err)
+		}
+		if len(route.Targets) == 0 {
+			return fmt.Errorf("No targets in route %d", i+1)
+		}
+		for _, target := range route.Targets {
+			if findNotifier(notifiers, target) == nil {
+				return fmt.Errorf("Unknown target %q in route %d", target, i+1)
+			}
+		}
+		for _, esc := range route.Escalations {
+			if len(esc.Targets) == 0 {
+				return fmt.Errorf("No targets in escalation in route %d", i+1)
+			}
+			for _, target := range esc.Targets {
+				if findNotifier(notifiers, target) == nil {
+					return fmt.Errorf("Unknown escalation target %q in route %d", target, i+1)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func findNotifier(notifiers []namedNotifier, name string) *namedNotifier {
+	for i := range notifiers {
+		if notifiers[i].name == name {
+			return &notifiers[i]
+		}
+	}
+	return nil
+}
+
+func matchGlob(pattern, s string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, s)
+	return ok
+}
+
+func matchStatus(statuses []string, status string) bool {
+	if len(statuses) == 0 {
+		return true
+	}
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func routeMatches(route hubutil.RouteConfig, check persist.CheckModel, from, to string) bool {
+	return matchGlob(route.Namespace, check.Check.Namespace) &&
+		matchGlob(route.Agent, check.Agent.Name) &&
+		(route.CheckType == "" || route.CheckType == check.Check.Type) &&
+		matchStatus(route.From, from) &&
+		matchStatus(route.To, to)
+}
+
+// matchingRoutes returns the indexes of the routes that match the check going from one status to another
+func (m *Monitor) matchingRoutes(check persist.CheckModel, from, to string) []int {
+	matching := []int{}
+	for i, route := range m.cfg.Routes {
+		if routeMatches(route, check, from, to) {
+			matching = append(matching, i)
+		}
+	}
+	return matching
+}
+
+// notifiersByName returns the named notifiers, each at most once
+func (m *Monitor) notifiersByName(names []string) []namedNotifier {
+	selected := []namedNotifier{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			selected = append(selected, *findNotifier(m.notifiers, name))
+		}
+	}
+	return selected
+}
+
+// route returns the notifiers that should get a notification about the check going from one status to another.
+// All matching routes are used and each notifier is returned at most once.
+func (m *Monitor) route(check persist.CheckModel, from, to string) []namedNotifier {
+	if len(m.cfg.Routes) == 0 {
+		return m.notifiers
+	}
+	targets := []string{}
+	for _, i := range m.matchingRoutes(check, from, to) {
+		targets = append(targets, m.cfg.Routes[i].Targets...)
+	}
+	return m.notifiersByName(targets)
+}
@@ -0,0 +1,104 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/chk"
+	"github.com/rymdhund/whazza/internal/hubutil"
+	"github.com/rymdhund/whazza/internal/persist"
+)
+
+func routedNames(m *Monitor, check persist.CheckModel, from, to string) []string {
+	names := []string{}
+	for _, n := range m.route(check, from, to) {
+		names = append(names, n.name)
+	}
+	return names
+}
+
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRoute(t *testing.T) {
+	cfg := hubutil.HubConfig{
+		Notifiers: []hubutil.NotifierConfig{
+			{Name: "ops", Type: "slack", URL: "http://localhost/ops"},
+			{Name: "db-team", Type: "slack", URL: "http://localhost/db"},
+			{Name: "pager", Type: "ntfy", URL: "http://localhost/pager"},
+		},
+		Routes: []hubutil.RouteConfig{
+			{Targets: []string{"ops"}},
+			{Namespace: "db:*", Targets: []string{"db-team", "ops"}},
+			{Agent: "prod-*", CheckType: "http-up", To: []string{"fail", "expired"}, Targets: []string{"pager"}},
+		},
+	}
+	m, err := New(cfg, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbCheck, _ := chk.New("tcp-port", "db:postgres", 60, []byte(`{"host": "db", "port": 5432}`))
+	webCheck, _ := chk.New("http-up", "web", 60, []byte(`{"host": "example.com"}`))
+	prod := persist.AgentModel{Name: "prod-1"}
+	test := persist.AgentModel{Name: "test-1"}
+
+	cases := []struct {
+		check    persist.CheckModel
+		from, to string
+		expected []string
+	}{
+		{persist.CheckModel{Check: dbCheck, Agent: test}, "good", "fail", []string{"ops", "db-team"}},
+		{persist.CheckModel{Check: webCheck, Agent: test}, "good", "fail", []string{"ops"}},
+		{persist.CheckModel{Check: webCheck, Agent: prod}, "good", "fail", []string{"ops", "pager"}},
+		{persist.CheckModel{Check: webCheck, Agent: prod}, "fail", "good", []string{"ops"}},
+		{persist.CheckModel{Check: dbCheck, Agent: prod}, "good", "fail", []string{"ops", "db-team"}},
+	}
+	for _, c := range cases {
+		names := routedNames(m, c.check, c.from, c.to)
+		if !equalNames(names, c.expected) {
+			t.Errorf("Expected %v for %s on %s %s->%s, got %v", c.expected, c.check.Check.Namespace, c.check.Agent.Name, c.from, c.to, names)
+		}
+	}
+}
+
+func TestRouteWithoutRoutes(t *testing.T) {
+	cfg := hubutil.HubConfig{
+		Notifiers: []hubutil.NotifierConfig{
+			{Name: "a", Type: "slack", URL: "http://localhost/a"},
+			{Name: "b", Type: "slack", URL: "http://localhost/b"},
+		},
+	}
+	m, err := New(cfg, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	check, _ := chk.New("http-up", "web", 60, []byte(`{"host": "example.com"}`))
+	names := routedNames(m, persist.CheckModel{Check: check}, "good", "fail")
+	if !equalNames(names, []string{"a", "b"}) {
+		t.Fatalf("Expected all notifiers, got %v", names)
+	}
+}
+
+func TestValidateRoutes(t *testing.T) {
+	notifiers := []hubutil.NotifierConfig{{Name: "ops", Type: "slack", URL: "http://localhost"}}
+	invalid := [][]hubutil.RouteConfig{
+		{{Targets: []string{"nobody"}}},
+		{{Namespace: "db:*"}},
+		{{Namespace: "[", Targets: []string{"ops"}}},
+	}
+	for _, routes := range invalid {
+		if _, err := New(hubutil.HubConfig{Notifiers: notifiers, Routes: routes}, time.Now()); err == nil {
+			t.Errorf("Expected error for %+v", routes)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Unlike agent tokens, passwords are chosen by humans and have little entropy, so we use a slow salted hash.
+
+const (
+	iterations = 200000
+	saltLen    = 16
+	keyLen     = 32
+	scheme     = "pbkdf2-sha256"
+)
+
+var ErrInvalidHash = errors.New("Invalid password hash")
+
+// dummyHash costs as much to verify as a real hash but matches no password
+var dummyHash = fmt.Sprintf("%s$%d$%s$%s",
+	scheme,
+	iterations,
+	base64.RawStdEncoding.EncodeToString(make([]byte, saltLen)),
+	base64.RawStdEncoding.EncodeToString(make([]byte, keyLen)),
+)
+
+// Hash returns an encoded salted hash of the password, like "pbkdf2-sha256$200000$<salt>$<key>"
+func Hash(password string) string {
+	salt := make([]byte, saltLen)
+	_, err := rand.Read(salt)
+	if err != nil {
+		panic(err)
+	}
+	key := pbkdf2.Key([]byte(password), salt, iterations, keyLen, sha256.New)
+	return fmt.Sprintf("%s$%d$%s$%s",
+		scheme,
+		iterations,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+// Verify returns true if password matches the encoded hash
+func Verify(password, encoded string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != scheme {
+		return false, ErrInvalidHash
+	}
+	iter, err := strconv.Atoi(parts[1])
+	if err != nil || iter <= 0 {
+		return false, ErrInvalidHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false, ErrInvalidHash
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false, ErrInvalidHash
+	}
+	key := pbkdf2.Key([]byte(password), salt, iter, len(expected), sha256.New)
+	return subtle.ConstantTimeCompare(key, expected) == 1, nil
+}
+
+// VerifyNothing takes as long as verifying a real hash, so that callers can hide whether a user exists
+func VerifyNothing(password string) {
+	Verify(password, dummyHash)
+}
@@ -0,0 +1,36 @@
+package password
+
+import (
+	"testing"
+)
+
+func TestVerifyKnownHash(t *testing.T) {
+	// The pbkdf2-hmac-sha256 test vector for "password" and "salt" with 4096 iterations
+	encoded := "pbkdf2-sha256$4096$c2FsdA$xeR41ZKIyEGqUw22hFxMjZYok6ABzk4RpJY4c6qYE0o"
+	ok, err := Verify("password", encoded)
+	if err != nil || !ok {
+		t.Fatalf("Expected match: %v", err)
+	}
+}
+
+func TestHashAndVerify(t *testing.T) {
+	hash := Hash("hunter2")
+	if hash == Hash("hunter2") {
+		t.Fatal("Expected different salts")
+	}
+
+	ok, err := Verify("hunter2", hash)
+	if err != nil || !ok {
+		t.Fatalf("Expected match: %s", err)
+	}
+	ok, err = Verify("hunter3", hash)
+	if err != nil || ok {
+		t.Fatalf("Expected no match: %s", err)
+	}
+	if ok, err := Verify("", dummyHash); err != nil || ok {
+		t.Fatalf("Expected the dummy hash to be valid and match nothing: %s", err)
+	}
+	if _, err := Verify("hunter2", "plaintext"); err != ErrInvalidHash {
+		t.Fatalf("Expected ErrInvalidHash, got %s", err)
+	}
+}
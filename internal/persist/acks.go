@@ -0,0 +1,68 @@
+package persist
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrNothingToAck = errors.New("The check has no problem to acknowledge")
+
+// AckCheck acknowledges the current problem of a check. Acks are stored on the latest notification so
+// that they are cleared when a recovery is notified. If the problem hasn't been notified, eg because it
+// is silenced, we record it as notified with the given status.
+func (db *DB) AckCheck(check CheckModel, status string, ack AckModel) error {
+	var (
+		notificationID int
+		lastStatus     string
+	)
+	err := db.QueryRow(
+		"SELECT id, status FROM notifications WHERE check_id = ? ORDER BY id DESC LIMIT 1",
+		check.ID,
+	).Scan(&notificationID, &lastStatus)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if lastStatus == "" || lastStatus == "good" {
+		if status == "good" {
+			return ErrNothingToAck
+		}
+		res, err := db.Exec("INSERT INTO notifications (check_id, status, timestamp) VALUES (?, ?, ?)", check.ID, status, ack.Timestamp.Unix())
+		if err != nil {
+			return err
+		}
+		id, _ := res.LastInsertId()
+		notificationID = int(id)
+	}
+
+	_, err = db.Exec(
+		"UPDATE notifications SET acked_by = ?, acked_at = ?, ack_comment = ? WHERE id = ?",
+		ack.User, ack.Timestamp.Unix(), ack.Comment, notificationID,
+	)
+	if err != nil {
+		return err
+	}
+	message := fmt.Sprintf("Acked %s: %s", check.Check.Title(), ack.Comment)
+	return addCheckEvent(db, check, EventAck, ack.User, message, ack.Timestamp)
+}
+
+// GetAck returns the ack of the current problem of a check, or nil if it is not acked
+func (db *DB) GetAck(checkID int) (*AckModel, error) {
+	var (
+		ackedAt int64
+		ack     = AckModel{CheckID: checkID}
+	)
+	err := db.QueryRow(
+		"SELECT acked_by, acked_at, ack_comment FROM notifications WHERE check_id = ? ORDER BY id DESC LIMIT 1",
+		checkID,
+	).Scan(&ack.User, &ackedAt, &ack.Comment)
+	if err == sql.ErrNoRows || (err == nil && ackedAt == 0) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	ack.Timestamp = time.Unix(ackedAt, 0)
+	return &ack, nil
+}
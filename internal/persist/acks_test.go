@@ -0,0 +1,60 @@
+package persist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/chk"
+)
+
+func TestAckCheck(t *testing.T) {
+	db := mkTestDb(t)
+	if err := db.SaveAgent("agent", ""); err != nil {
+		t.Fatal(err)
+	}
+	check, _ := chk.New("http-up", "ns", 60, []byte(`{"host":"example.com"}`))
+	cm, err := db.RegisterCheck(AgentModel{1, "agent"}, check)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ack := AckModel{User: "alice", Comment: "on it", Timestamp: time.Unix(1600000000, 0)}
+
+	if err := db.AckCheck(cm, "good", ack); err != ErrNothingToAck {
+		t.Fatalf("Expected ErrNothingToAck, got %v", err)
+	}
+
+	if err := db.AddNotification(cm.ID, "fail"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AckCheck(cm, "fail", ack); err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.GetAck(cm.ID)
+	if err != nil || got == nil || got.User != "alice" || got.Comment != "on it" || !got.Timestamp.Equal(ack.Timestamp) {
+		t.Fatalf("Unexpected ack %v %v", got, err)
+	}
+	status, _ := db.LastNotification(cm.ID)
+	if status != "fail" {
+		t.Fatalf("Ack should not change the notified status, got %s", status)
+	}
+
+	// A recovery clears the ack
+	if err := db.AddNotification(cm.ID, "good"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := db.GetAck(cm.ID); err != nil || got != nil {
+		t.Fatalf("Expected no ack after recovery, got %v %v", got, err)
+	}
+
+	// A problem that wasn't notified is recorded when acked
+	if err := db.AckCheck(cm, "expired", ack); err != nil {
+		t.Fatal(err)
+	}
+	status, _ = db.LastNotification(cm.ID)
+	if status != "expired" {
+		t.Fatalf("Expected expired to be recorded, got %s", status)
+	}
+	if got, err := db.GetAck(cm.ID); err != nil || got == nil {
+		t.Fatalf("Expected ack, got %v %v", got, err)
+	}
+}
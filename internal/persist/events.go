@@ -0,0 +1,145 @@
+package persist
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Default max number of events returned by GetEvents
+const defaultEventLimit = 100
+
+// EventFilter selects events. Empty fields match anything, namespace is a glob pattern.
+type EventFilter struct {
+	Kind      string
+	CheckID   int
+	Agent     string
+	Namespace string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+func (db *DB) AddEvent(e EventModel) error {
+	return addEvent(db, e)
+}
+
+func addEvent(db querier, e EventModel) error {
+	_, err := db.Exec(
+		`INSERT INTO events
+		(timestamp, kind, check_id, agent, namespace, status, user, message)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Timestamp.Unix(), e.Kind, e.CheckID, e.Agent, e.Namespace, e.Status, e.User, e.Message,
+	)
+	return err
+}
+
+// addCheckEvent records an event about a check
+func addCheckEvent(db querier, check CheckModel, kind, user, message string, t time.Time) error {
+	return addEvent(db, EventModel{
+		Timestamp: t,
+		Kind:      kind,
+		CheckID:   check.ID,
+		Agent:     check.Agent.Name,
+		Namespace: check.Check.Namespace,
+		User:      user,
+		Message:   message,
+	})
+}
+
+// AddStatusEvent records a status transition of a check if the status differs from the last recorded one
+func (db *DB) AddStatusEvent(check CheckModel, status, msg string, t time.Time) error {
+	return addStatusEvent(db, check, status, msg, t)
+}
+
+func addStatusEvent(db querier, check CheckModel, status, msg string, t time.Time) error {
+	var lastStatus string
+	err := db.QueryRow(
+		"SELECT status FROM events WHERE check_id = ? AND kind = ? ORDER BY id DESC LIMIT 1",
+		check.ID, EventStatus,
+	).Scan(&lastStatus)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if lastStatus == status {
+		return nil
+	}
+
+	message := fmt.Sprintf("%s: %s", check.Check.Title(), status)
+	if lastStatus != "" {
+		message = fmt.Sprintf("%s: %s -> %s", check.Check.Title(), lastStatus, status)
+	}
+	if msg != "" {
+		message += " | " + msg
+	}
+	return addEvent(db, EventModel{
+		Timestamp: t,
+		Kind:      EventStatus,
+		CheckID:   check.ID,
+		Agent:     check.Agent.Name,
+		Namespace: check.Check.Namespace,
+		Status:    status,
+		Message:   message,
+	})
+}
+
+// GetEvents returns the events matching the filter, newest first
+func (db *DB) GetEvents(f EventFilter) ([]EventModel, error) {
+	conds := []string{"1"}
+	args := []interface{}{}
+	if f.Kind != "" {
+		conds = append(conds, "kind = ?")
+		args = append(args, f.Kind)
+	}
+	if f.CheckID != 0 {
+		conds = append(conds, "check_id = ?")
+		args = append(args, f.CheckID)
+	}
+	if f.Agent != "" {
+		conds = append(conds, "agent = ?")
+		args = append(args, f.Agent)
+	}
+	if f.Namespace != "" {
+		conds = append(conds, "namespace GLOB ?")
+		args = append(args, f.Namespace)
+	}
+	if !f.Since.IsZero() {
+		conds = append(conds, "timestamp >= ?")
+		args = append(args, f.Since.Unix())
+	}
+	if !f.Until.IsZero() {
+		conds = append(conds, "timestamp < ?")
+		args = append(args, f.Until.Unix())
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultEventLimit
+	}
+	args = append(args, limit)
+
+	rows, err := db.Query(
+		`SELECT id, timestamp, kind, check_id, agent, namespace, status, user, message FROM events
+		WHERE `+strings.Join(conds, " AND ")+` ORDER BY timestamp DESC, id DESC LIMIT ?`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []EventModel{}
+	for rows.Next() {
+		var (
+			e  EventModel
+			ts int64
+		)
+		err := rows.Scan(&e.ID, &ts, &e.Kind, &e.CheckID, &e.Agent, &e.Namespace, &e.Status, &e.User, &e.Message)
+		if err != nil {
+			return nil, err
+		}
+		e.Timestamp = time.Unix(ts, 0)
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
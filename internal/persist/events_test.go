@@ -0,0 +1,72 @@
+package persist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/base"
+	"github.com/rymdhund/whazza/internal/chk"
+)
+
+func TestEvents(t *testing.T) {
+	db := mkTestDb(t)
+	if err := db.SaveAgent("agent", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveAgent("agent", "newhash"); err != nil {
+		t.Fatal(err)
+	}
+	agent := AgentModel{1, "agent"}
+	check, _ := chk.New("http-up", "web", 60, []byte(`{"host":"example.com"}`))
+	cm, err := db.RegisterCheck(agent, check)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check.Interval = 120
+	if _, err := db.RegisterCheck(agent, check); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Unix(1600000000, 0)
+	for i, status := range []string{"good", "good", "fail", "fail", "good"} {
+		_, err := db.AddResult(agent, cm, base.Result{Status: status, Msg: "", Timestamp: start.Add(time.Duration(i) * time.Minute)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.AddStatusEvent(cm, "expired", "", start.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := db.GetEvents(EventFilter{Kind: EventStatus})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"expired", "good", "fail", "good"}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d status events, got %+v", len(expected), events)
+	}
+	for i, status := range expected {
+		if events[i].Status != status || events[i].CheckID != cm.ID || events[i].Namespace != "web" {
+			t.Errorf("Unexpected event %d: %+v", i, events[i])
+		}
+	}
+	if events[2].Message != "http:example.com: good -> fail" || !events[2].Timestamp.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("Unexpected event %+v", events[2])
+	}
+
+	events, err = db.GetEvents(EventFilter{Kind: EventStatus, Since: start.Add(time.Minute), Until: start.Add(time.Hour), Limit: 1})
+	if err != nil || len(events) != 1 || events[0].Status != "good" {
+		t.Fatalf("Unexpected events %+v %v", events, err)
+	}
+
+	for _, kind := range []string{EventAgentRegistered, EventAgentRekeyed, EventCheckAdded, EventCheckChanged} {
+		events, err := db.GetEvents(EventFilter{Kind: kind, Agent: "agent"})
+		if err != nil || len(events) != 1 {
+			t.Errorf("Expected one %s event, got %+v %v", kind, events, err)
+		}
+	}
+	if events, _ := db.GetEvents(EventFilter{Namespace: "db*"}); len(events) != 0 {
+		t.Errorf("Expected no events in namespace db*, got %+v", events)
+	}
+}
@@ -1,11 +1,15 @@
 package persist
 
 import (
+	"errors"
 	"fmt"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/rymdhund/whazza/internal/base"
 	"github.com/rymdhund/whazza/internal/chk"
+	"github.com/rymdhund/whazza/internal/cron"
 	"github.com/rymdhund/whazza/internal/utils"
 )
 
@@ -14,16 +18,62 @@ type AgentModel struct {
 	Name string
 }
 
+type UserModel struct {
+	ID   int
+	Name string
+	Role string
+}
+
+// ApiTokenModel describes an api token of a user. The token itself is only shown when it is created.
+type ApiTokenModel struct {
+	ID      int
+	Name    string
+	Created time.Time
+}
+
+// User roles, each role can do everything the roles before it can
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+)
+
+var roleRanks = map[string]int{RoleViewer: 1, RoleOperator: 2}
+
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// HasRole returns true if the user has at least the given role
+func (u UserModel) HasRole(role string) bool {
+	return roleRanks[u.Role] >= roleRanks[role] && roleRanks[u.Role] > 0
+}
+
 type CheckModel struct {
 	ID    int
 	Check chk.Check
 	Agent AgentModel
+	// Retired is when the agent stopped running the check, zero for active checks. Retired checks
+	// don't expire and are hidden by default.
+	Retired time.Time
 }
 
 type ResultModel struct {
 	ID int
 	base.Result
 	CheckID int
+	// Last is set for rolled up results to the time of the last result they replace, and Count
+	// is the number of results they replace
+	Last  time.Time
+	Count int
+}
+
+// LastTimestamp is the time of the last result this result stands for
+func (r ResultModel) LastTimestamp() time.Time {
+	if r.Last.After(r.Timestamp) {
+		return r.Last
+	}
+	return r.Timestamp
 }
 
 type CheckOverview struct {
@@ -32,19 +82,184 @@ type CheckOverview struct {
 	LastReceived base.Result
 	LastGood     base.Result
 	LastFail     base.Result
+	// Silenced is true if notifications for the check are currently silenced
+	Silenced bool
+	// Ack is set if someone has acknowledged the current problem
+	Ack *AckModel
+}
+
+type NotificationModel struct {
+	ID        int
+	CheckID   int
+	Status    string
+	Kind      string
+	Timestamp time.Time
+}
+
+// Kinds of events
+const (
+	EventStatus          = "status"
+	EventCheckAdded      = "check_added"
+	EventCheckChanged    = "check_changed"
+	EventCheckRetired    = "check_retired"
+	EventCheckRestored   = "check_restored"
+	EventCheckDeleted    = "check_deleted"
+	EventAgentRegistered = "agent_registered"
+	EventAgentRekeyed    = "agent_rekeyed"
+	EventSilenceAdded    = "silence_added"
+	EventSilenceExpired  = "silence_expired"
+	EventAck             = "ack"
+)
+
+// EventModel is an entry in the event log. Check, agent, namespace, status and user are set when relevant.
+type EventModel struct {
+	ID        int
+	Timestamp time.Time
+	Kind      string
+	CheckID   int
+	Agent     string
+	Namespace string
+	Status    string
+	User      string
+	Message   string
+}
+
+func (e EventModel) Show() string {
+	scope := ""
+	if e.Namespace != "" {
+		scope += fmt.Sprintf(" [%s]", e.Namespace)
+	}
+	if e.CheckID != 0 {
+		scope += fmt.Sprintf(" check %d", e.CheckID)
+	}
+	if e.Agent != "" {
+		scope += fmt.Sprintf(" on %s", e.Agent)
+	}
+	user := ""
+	if e.User != "" {
+		user = fmt.Sprintf(" (by %s)", e.User)
+	}
+	return fmt.Sprintf("%s %s%s | %s%s", e.Timestamp.Format("2006-01-02 15:04:05"), e.Kind, scope, e.Message, user)
+}
+
+// AckModel is an acknowledgement of a problem with a check. It lasts until the check recovers.
+type AckModel struct {
+	CheckID   int
+	User      string
+	Comment   string
+	Timestamp time.Time
+}
+
+// SilenceModel suppresses notifications for matching checks. The scope fields that are set must all match.
+//
+// A silence without schedule is active from Start to End. A silence with a cron schedule is active for
+// Duration every time the schedule fires, between Start and End. A zero End means that it never ends.
+type SilenceModel struct {
+	ID        int
+	Namespace string // glob pattern
+	Agent     string
+	CheckID   int
+	Start     time.Time
+	End       time.Time
+	Schedule  string
+	Duration  time.Duration
+	Comment   string
+	CreatedBy string
+	Created   time.Time
+}
+
+func (s SilenceModel) Validate() error {
+	if s.Namespace == "" && s.Agent == "" && s.CheckID == 0 {
+		return errors.New("A silence needs a namespace, agent or check id")
+	}
+	if _, err := path.Match(s.Namespace, ""); err != nil {
+		return fmt.Errorf("Invalid namespace pattern: %w", err)
+	}
+	if s.Schedule == "" {
+		if s.End.IsZero() {
+			return errors.New("A silence without schedule needs an end time")
+		}
+	} else {
+		if _, err := cron.Parse(s.Schedule); err != nil {
+			return err
+		}
+		if s.Duration <= 0 {
+			return errors.New("A scheduled silence needs a duration")
+		}
+	}
+	if !s.End.IsZero() && !s.End.After(s.Start) {
+		return errors.New("The silence must end after it starts")
+	}
+	return nil
+}
+
+func (s SilenceModel) Matches(check CheckModel) bool {
+	if s.Namespace != "" {
+		if ok, _ := path.Match(s.Namespace, check.Check.Namespace); !ok {
+			return false
+		}
+	}
+	return (s.Agent == "" || s.Agent == check.Agent.Name) && (s.CheckID == 0 || s.CheckID == check.ID)
+}
+
+func (s SilenceModel) Expired(now time.Time) bool {
+	return !s.End.IsZero() && !s.End.After(now)
+}
+
+func (s SilenceModel) ActiveAt(t time.Time) bool {
+	if t.Before(s.Start) || s.Expired(t) {
+		return false
+	}
+	if s.Schedule == "" {
+		return true
+	}
+	schedule, err := cron.Parse(s.Schedule)
+	if err != nil {
+		return false
+	}
+	return schedule.ActiveAt(t, s.Duration)
+}
+
+// Scope describes what the silence matches in a human readable way
+func (s SilenceModel) Scope() string {
+	parts := []string{}
+	if s.Namespace != "" {
+		parts = append(parts, fmt.Sprintf("namespace %s", s.Namespace))
+	}
+	if s.Agent != "" {
+		parts = append(parts, fmt.Sprintf("agent %s", s.Agent))
+	}
+	if s.CheckID != 0 {
+		parts = append(parts, fmt.Sprintf("check %d", s.CheckID))
+	}
+	return strings.Join(parts, ", ")
 }
 
 func (o *CheckOverview) Show() string {
 	now := time.Now()
 
 	extra := ""
-	if o.Result.Status == "fail" {
+	switch o.Result.Status {
+	case "fail", "error":
 		extra = fmt.Sprintf(" | %s | last good: %s", o.Result.Msg, utils.HumanRelTime(now, o.LastGood.Timestamp, false))
+	case "warning":
+		extra = fmt.Sprintf(" | %s", o.Result.Msg)
+	}
+
+	status := o.Result.Status
+	if o.Silenced {
+		status += " (silenced)"
+	}
+	if o.Ack != nil {
+		status += fmt.Sprintf(" (acked by %s)", o.Ack.User)
+	}
+	if !o.CheckModel.Retired.IsZero() {
+		status += fmt.Sprintf(" (retired %s)", utils.HumanRelTime(now, o.CheckModel.Retired, false))
 	}
 
 	return fmt.Sprintf("[%s] %s | %s | %s%s",
 		o.CheckModel.Check.Namespace,
-		o.Result.Status,
+		status,
 		o.CheckModel.Check.Title(),
 		utils.HumanRelTime(now, o.LastReceived.Timestamp, false),
 		extra,
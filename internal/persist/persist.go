@@ -3,6 +3,7 @@ package persist
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3" // sqlite
@@ -19,6 +20,13 @@ type Tx struct {
 	*sql.Tx
 }
 
+// querier is implemented by both DB and Tx, for functions that can run both in and outside a transaction
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 // Open returns a DB reference for a data source.
 func Open(filename string) (*DB, error) {
 	connString := fmt.Sprintf("file:%s?_busy_timeout=10000&mode=rwc&_journal_mode=WAL", filename)
@@ -34,6 +42,8 @@ func OpenMemory() (*DB, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Every connection gets its own in-memory database so we can only use one
+	db.SetMaxOpenConns(1)
 	return &DB{db}, nil
 
 }
@@ -47,6 +57,24 @@ func (db *DB) Begin() (*Tx, error) {
 	return &Tx{tx}, nil
 }
 
+// Savepoint runs f in a savepoint, so that the changes of f are rolled back if it fails while the rest of
+// the transaction continues. It returns the error of f, and err if the savepoint itself fails.
+func (tx *Tx) Savepoint(f func() error) (fErr error, err error) {
+	_, err = tx.Exec("SAVEPOINT sp")
+	if err != nil {
+		return nil, err
+	}
+	fErr = f()
+	if fErr != nil {
+		_, err = tx.Exec("ROLLBACK TO sp")
+		if err != nil {
+			return fErr, err
+		}
+	}
+	_, err = tx.Exec("RELEASE sp")
+	return fErr, err
+}
+
 func (db *DB) Init() error {
 	_, err := db.Exec(`
 	CREATE TABLE IF NOT EXISTS agents (
@@ -67,6 +95,9 @@ func (db *DB) Init() error {
 		namespace TEXT NOT NULL,
 		interval INTEGER NOT NULL,
 		checker_json JSON NOT NULL,
+		alert_after INTEGER NOT NULL DEFAULT 0,
+		recover_after INTEGER NOT NULL DEFAULT 0,
+		retired_at INTEGER NOT NULL DEFAULT 0,
 		FOREIGN KEY(agent_id) REFERENCES agents(id)
 	)
 	`)
@@ -74,6 +105,20 @@ func (db *DB) Init() error {
 		return err
 	}
 
+	// Columns added after the first release
+	err = db.addColumnIfMissing("checks", "alert_after", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	err = db.addColumnIfMissing("checks", "recover_after", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	err = db.addColumnIfMissing("checks", "retired_at", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+
 	_, err = db.Exec(`
 		CREATE UNIQUE INDEX IF NOT EXISTS idx_checks_big ON checks(agent_id, type, namespace, checker_json)
 	`)
@@ -88,6 +133,8 @@ func (db *DB) Init() error {
 		status TEXT NOT NULL,
 		status_msg TEXT NOT NULL,
 		timestamp INTEGER NOT NULL,
+		last_timestamp INTEGER NOT NULL DEFAULT 0,
+		count INTEGER NOT NULL DEFAULT 1,
 		FOREIGN KEY(check_id) REFERENCES checks(id)
 	)
 	`)
@@ -95,11 +142,143 @@ func (db *DB) Init() error {
 		return err
 	}
 
+	// Rolled up results are runs of the same status within an hour, see CompactResults
+	err = db.addColumnIfMissing("results", "last_timestamp", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	err = db.addColumnIfMissing("results", "count", "INTEGER NOT NULL DEFAULT 1")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_results_check ON results(check_id, timestamp)
+	`)
+	if err != nil {
+		return err
+	}
+
 	_, err = db.Exec(`
 	CREATE TABLE IF NOT EXISTS notifications (
 		id INTEGER PRIMARY KEY,
 		check_id INTEGER NOT NULL,
-		status TEXT NOT NULL
+		status TEXT NOT NULL,
+		acked_by TEXT NOT NULL DEFAULT '',
+		acked_at INTEGER NOT NULL DEFAULT 0,
+		ack_comment TEXT NOT NULL DEFAULT '',
+		timestamp INTEGER NOT NULL DEFAULT 0,
+		kind TEXT NOT NULL DEFAULT ''
+	)
+	`)
+	if err != nil {
+		return err
+	}
+
+	for _, col := range []struct{ name, definition string }{
+		{"acked_by", "TEXT NOT NULL DEFAULT ''"},
+		{"acked_at", "INTEGER NOT NULL DEFAULT 0"},
+		{"ack_comment", "TEXT NOT NULL DEFAULT ''"},
+		{"timestamp", "INTEGER NOT NULL DEFAULT 0"},
+		{"kind", "TEXT NOT NULL DEFAULT ''"},
+	} {
+		err = db.addColumnIfMissing("notifications", col.name, col.definition)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS silences (
+		id INTEGER PRIMARY KEY,
+		namespace TEXT NOT NULL,
+		agent TEXT NOT NULL,
+		check_id INTEGER NOT NULL,
+		starts INTEGER NOT NULL,
+		ends INTEGER NOT NULL,
+		schedule TEXT NOT NULL,
+		duration INTEGER NOT NULL,
+		comment TEXT NOT NULL,
+		created_by TEXT NOT NULL,
+		created INTEGER NOT NULL
+	)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS events (
+		id INTEGER PRIMARY KEY,
+		timestamp INTEGER NOT NULL,
+		kind TEXT NOT NULL,
+		check_id INTEGER NOT NULL,
+		agent TEXT NOT NULL,
+		namespace TEXT NOT NULL,
+		status TEXT NOT NULL,
+		user TEXT NOT NULL,
+		message TEXT NOT NULL
+	)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_events_check ON events(check_id, kind)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS digest_runs (
+		name TEXT PRIMARY KEY,
+		last_run INTEGER NOT NULL
+	)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY,
+		name TEXT UNIQUE NOT NULL,
+		password_hash TEXT NOT NULL,
+		role TEXT NOT NULL
+	)
+	`)
+	if err != nil {
+		return err
+	}
+	// There used to be an admin role, but nothing needed more than operator
+	_, err = db.Exec("UPDATE users SET role = ? WHERE role = 'admin'", RoleOperator)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS sessions (
+		id INTEGER PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		token_hash TEXT UNIQUE NOT NULL,
+		expires INTEGER NOT NULL,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS api_tokens (
+		id INTEGER PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		token_hash TEXT UNIQUE NOT NULL,
+		created INTEGER NOT NULL,
+		FOREIGN KEY(user_id) REFERENCES users(id)
 	)
 	`)
 	if err != nil {
@@ -109,27 +288,72 @@ func (db *DB) Init() error {
 	return nil
 }
 
+// addColumnIfMissing adds a column to an existing table, used to migrate databases created by older versions
+func (db *DB) addColumnIfMissing(table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, typ        string
+			dflt             sql.NullString
+		)
+		err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk)
+		if err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
 func (db *DB) AddCheck(agent AgentModel, check chk.Check) (CheckModel, error) {
+	return addCheck(db, agent, check)
+}
+
+func addCheck(db querier, agent AgentModel, check chk.Check) (CheckModel, error) {
 	res, err := db.Exec(
 		`INSERT INTO checks
-		(agent_id, type, namespace, interval, checker_json)
-		VALUES (?, ?, ?, ?, ?)`,
-		agent.ID, check.Type, check.Namespace, check.Interval, check.Checker.AsJson())
+		(agent_id, type, namespace, interval, checker_json, alert_after, recover_after)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		agent.ID, check.Type, check.Namespace, check.Interval, check.Checker.AsJson(), check.AlertAfter, check.RecoverAfter)
 	if err != nil {
 		return CheckModel{}, err
 	}
 
 	id, _ := res.LastInsertId()
-	return CheckModel{int(id), check, agent}, nil
+	return CheckModel{ID: int(id), Check: check, Agent: agent}, nil
 }
 
+// RegisterCheck returns the check, which is added if it doesn't exist or updated if its interval or
+// thresholds changed
 func (db *DB) RegisterCheck(agent AgentModel, check chk.Check) (CheckModel, error) {
+	return registerCheck(db, agent, check)
+}
+
+func (tx *Tx) RegisterCheck(agent AgentModel, check chk.Check) (CheckModel, error) {
+	return registerCheck(tx, agent, check)
+}
+
+func registerCheck(db querier, agent AgentModel, check chk.Check) (CheckModel, error) {
 	var (
-		checkID  int64
-		interval int
+		checkID, retiredAt                 int64
+		interval, alertAfter, recoverAfter int
 	)
 	err := db.QueryRow(
-		`SELECT id, interval FROM checks WHERE
+		`SELECT id, interval, alert_after, recover_after, retired_at FROM checks WHERE
 		  agent_id = ? AND
 		  type = ? AND
 		  namespace = ? AND
@@ -138,10 +362,14 @@ func (db *DB) RegisterCheck(agent AgentModel, check chk.Check) (CheckModel, erro
 		check.Type,
 		check.Namespace,
 		check.Checker.AsJson(),
-	).Scan(&checkID, &interval)
+	).Scan(&checkID, &interval, &alertAfter, &recoverAfter, &retiredAt)
 	switch {
 	case err == sql.ErrNoRows:
-		checkModel, err := db.AddCheck(agent, check)
+		checkModel, err := addCheck(db, agent, check)
+		if err != nil {
+			return CheckModel{}, err
+		}
+		err = addCheckEvent(db, checkModel, EventCheckAdded, "", fmt.Sprintf("New check %s", check.Title()), time.Now())
 		if err != nil {
 			return CheckModel{}, err
 		}
@@ -149,19 +377,50 @@ func (db *DB) RegisterCheck(agent AgentModel, check chk.Check) (CheckModel, erro
 	case err != nil:
 		return CheckModel{}, err
 	default:
-		// Update interval if changed
-		if interval != check.Interval {
-			_, err := db.Exec("UPDATE checks SET interval = ? WHERE id = ?", check.Interval, checkID)
+		checkModel := CheckModel{ID: int(checkID), Check: check, Agent: agent, Retired: timeOrZero(retiredAt)}
+		// Update interval and thresholds if changed
+		if interval != check.Interval || alertAfter != check.AlertAfter || recoverAfter != check.RecoverAfter {
+			_, err := db.Exec(
+				"UPDATE checks SET interval = ?, alert_after = ?, recover_after = ? WHERE id = ?",
+				check.Interval, check.AlertAfter, check.RecoverAfter, checkID,
+			)
+			if err != nil {
+				return CheckModel{}, err
+			}
+			changes := []string{}
+			for _, c := range []struct {
+				name     string
+				old, new int
+			}{
+				{"interval", interval, check.Interval},
+				{"alert after", alertAfter, check.AlertAfter},
+				{"recover after", recoverAfter, check.RecoverAfter},
+			} {
+				if c.old != c.new {
+					changes = append(changes, fmt.Sprintf("%s %d -> %d", c.name, c.old, c.new))
+				}
+			}
+			message := fmt.Sprintf("Changed %s: %s", check.Title(), strings.Join(changes, ", "))
+			err = addCheckEvent(db, checkModel, EventCheckChanged, "", message, time.Now())
 			if err != nil {
 				return CheckModel{}, err
 			}
 		}
 
-		return CheckModel{int(checkID), check, agent}, nil
+		return checkModel, nil
 	}
 }
 
+// AddResult stores a result of a check. Results newer than the retirement of a retired check restore it.
 func (db *DB) AddResult(agent AgentModel, check CheckModel, res base.Result) (ResultModel, error) {
+	return addResult(db, agent, check, res)
+}
+
+func (tx *Tx) AddResult(agent AgentModel, check CheckModel, res base.Result) (ResultModel, error) {
+	return addResult(tx, agent, check, res)
+}
+
+func addResult(db querier, agent AgentModel, check CheckModel, res base.Result) (ResultModel, error) {
 	r, err := db.Exec(
 		`INSERT INTO results
 		(check_id, status, status_msg, timestamp)
@@ -173,7 +432,24 @@ func (db *DB) AddResult(agent AgentModel, check CheckModel, res base.Result) (Re
 
 	id, _ := r.LastInsertId()
 
-	return ResultModel{int(id), res, check.ID}, nil
+	// Results from before the check was retired, eg queued on the agent, don't restore it
+	if !check.Retired.IsZero() && !res.Timestamp.Before(check.Retired) {
+		_, err := db.Exec("UPDATE checks SET retired_at = 0 WHERE id = ?", check.ID)
+		if err != nil {
+			return ResultModel{}, err
+		}
+		err = addCheckEvent(db, check, EventCheckRestored, "", fmt.Sprintf("Restored retired check %s", check.Check.Title()), res.Timestamp)
+		if err != nil {
+			return ResultModel{}, err
+		}
+	}
+
+	err = addStatusEvent(db, check, res.Status, res.Msg, res.Timestamp)
+	if err != nil {
+		return ResultModel{}, err
+	}
+
+	return ResultModel{ID: int(id), Result: res, CheckID: check.ID, Count: 1}, nil
 }
 
 func (db *DB) AuthenticateAgent(name string, token sectoken.SecToken) (AgentModel, bool, error) {
@@ -190,8 +466,16 @@ func (db *DB) AuthenticateAgent(name string, token sectoken.SecToken) (AgentMode
 	}
 }
 
+// SaveAgent registers an agent or sets a new token for an existing agent
 func (db *DB) SaveAgent(name string, tokenHash string) error {
-	_, err := db.Exec(
+	var id int
+	err := db.QueryRow("SELECT id FROM agents WHERE name = ?", name).Scan(&id)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	existed := err == nil
+
+	_, err = db.Exec(
 		`INSERT INTO agents
 		(name, token_hash)
 		VALUES (?, ?)
@@ -200,13 +484,34 @@ func (db *DB) SaveAgent(name string, tokenHash string) error {
 	if err != nil {
 		return err
 	}
-	return nil
+
+	e := EventModel{Timestamp: time.Now(), Kind: EventAgentRegistered, Agent: name, Message: fmt.Sprintf("Registered agent %s", name)}
+	if existed {
+		e.Kind = EventAgentRekeyed
+		e.Message = fmt.Sprintf("New token for agent %s", name)
+	}
+	return db.AddEvent(e)
+}
+
+// GetChecks returns the active checks, and the retired checks if all is true
+func (db *DB) GetChecks(all bool) ([]CheckModel, error) {
+	if all {
+		return db.getChecks("1")
+	}
+	return db.getChecks("c.retired_at = 0")
+}
+
+// GetAgentChecks returns the active checks registered by an agent
+func (db *DB) GetAgentChecks(agent AgentModel) ([]CheckModel, error) {
+	return db.getChecks("a.id = ? AND c.retired_at = 0", agent.ID)
 }
 
-func (db *DB) GetChecks() ([]CheckModel, error) {
+// getChecks returns the checks matching a condition on the checks c and their agents a
+func (db *DB) getChecks(where string, args ...interface{}) ([]CheckModel, error) {
 	rows, err := db.Query(
-		`SELECT c.id, c.type, c.namespace, c.interval, c.checker_json, a.id, a.name FROM checks c
-		JOIN agents a ON c.agent_id = a.id`)
+		`SELECT c.id, c.type, c.namespace, c.interval, c.checker_json, c.alert_after, c.recover_after, c.retired_at, a.id, a.name FROM checks c
+		JOIN agents a ON c.agent_id = a.id
+		WHERE `+where, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -217,7 +522,8 @@ func (db *DB) GetChecks() ([]CheckModel, error) {
 	for rows.Next() {
 		var c CheckModel
 		var typ, namespace string
-		var interval int
+		var interval, alertAfter, recoverAfter int
+		var retiredAt int64
 		var jsonData []byte
 		err := rows.Scan(
 			&c.ID,
@@ -225,6 +531,9 @@ func (db *DB) GetChecks() ([]CheckModel, error) {
 			&namespace,
 			&interval,
 			&jsonData,
+			&alertAfter,
+			&recoverAfter,
+			&retiredAt,
 			&c.Agent.ID,
 			&c.Agent.Name,
 		)
@@ -235,6 +544,9 @@ func (db *DB) GetChecks() ([]CheckModel, error) {
 		if err != nil {
 			return nil, err
 		}
+		c.Check.AlertAfter = alertAfter
+		c.Check.RecoverAfter = recoverAfter
+		c.Retired = timeOrZero(retiredAt)
 		checks = append(checks, c)
 	}
 	return checks, nil
@@ -248,7 +560,7 @@ func (db *DB) GetCheckOverview(check CheckModel) (overview CheckOverview, err er
 
 	// last res
 	err = db.QueryRow(
-		"SELECT status, status_msg, timestamp FROM results WHERE check_id = ? ORDER BY timestamp DESC LIMIT 1", check.ID,
+		"SELECT status, status_msg, MAX(timestamp, last_timestamp) FROM results WHERE check_id = ? ORDER BY timestamp DESC LIMIT 1", check.ID,
 	).Scan(&lastRes.Status, &lastRes.Msg, &timestamp)
 	switch {
 	case err == sql.ErrNoRows:
@@ -261,7 +573,7 @@ func (db *DB) GetCheckOverview(check CheckModel) (overview CheckOverview, err er
 
 	// last good
 	err = db.QueryRow(
-		"SELECT status, status_msg, timestamp FROM results WHERE check_id = ? AND status = 'good' ORDER BY timestamp DESC LIMIT 1", check.ID,
+		"SELECT status, status_msg, MAX(timestamp, last_timestamp) FROM results WHERE check_id = ? AND status = 'good' ORDER BY timestamp DESC LIMIT 1", check.ID,
 	).Scan(&lastGood.Status, &lastGood.Msg, &timestamp)
 	switch {
 	case err == sql.ErrNoRows:
@@ -274,7 +586,7 @@ func (db *DB) GetCheckOverview(check CheckModel) (overview CheckOverview, err er
 
 	// last fail
 	err = db.QueryRow(
-		"SELECT status, status_msg, timestamp FROM results WHERE check_id = ? AND status = 'fail' ORDER BY timestamp DESC LIMIT 1", check.ID,
+		"SELECT status, status_msg, MAX(timestamp, last_timestamp) FROM results WHERE check_id = ? AND status = 'fail' ORDER BY timestamp DESC LIMIT 1", check.ID,
 	).Scan(&lastFail.Status, &lastFail.Msg, &timestamp)
 	switch {
 	case err == sql.ErrNoRows:
@@ -288,7 +600,7 @@ func (db *DB) GetCheckOverview(check CheckModel) (overview CheckOverview, err er
 	var result base.Result
 	if (lastRes != base.Result{}) {
 		now := time.Now()
-		if check.Check.IsExpired(lastRes.Timestamp, now) {
+		if check.Retired.IsZero() && check.Check.IsExpired(lastRes.Timestamp, now) {
 			result = base.ExpiredResult()
 		} else {
 			result = lastRes
@@ -297,11 +609,30 @@ func (db *DB) GetCheckOverview(check CheckModel) (overview CheckOverview, err er
 		result = base.NoDataResult()
 	}
 
-	return CheckOverview{CheckModel: check, Result: result, LastReceived: lastRes, LastGood: lastGood, LastFail: lastFail}, nil
+	silences, err := db.ActiveSilences(check, time.Now())
+	if err != nil {
+		return
+	}
+
+	ack, err := db.GetAck(check.ID)
+	if err != nil {
+		return
+	}
+
+	return CheckOverview{
+		CheckModel:   check,
+		Result:       result,
+		LastReceived: lastRes,
+		LastGood:     lastGood,
+		LastFail:     lastFail,
+		Silenced:     len(silences) > 0,
+		Ack:          ack,
+	}, nil
 }
 
-func (db *DB) GetCheckOverviews() ([]CheckOverview, error) {
-	checks, err := db.GetChecks()
+// GetCheckOverviews returns overviews of the active checks, and the retired checks if all is true
+func (db *DB) GetCheckOverviews(all bool) ([]CheckOverview, error) {
+	checks, err := db.GetChecks(all)
 	if err != nil {
 		return nil, err
 	}
@@ -319,10 +650,11 @@ func (db *DB) GetCheckOverviews() ([]CheckOverview, error) {
 func (db *DB) GetCheckById(ID int) (CheckModel, error) {
 	var c CheckModel
 	var typ, namespace string
-	var interval int
+	var interval, alertAfter, recoverAfter int
+	var retiredAt int64
 	var jsonData []byte
 	err := db.QueryRow(
-		`SELECT c.id, c.type, c.namespace, c.interval, c.checker_json, a.id, a.name
+		`SELECT c.id, c.type, c.namespace, c.interval, c.checker_json, c.alert_after, c.recover_after, c.retired_at, a.id, a.name
 		FROM checks c
 		JOIN agents a ON c.agent_id = a.id
 		WHERE c.id = ?`,
@@ -333,6 +665,9 @@ func (db *DB) GetCheckById(ID int) (CheckModel, error) {
 		&namespace,
 		&interval,
 		&jsonData,
+		&alertAfter,
+		&recoverAfter,
+		&retiredAt,
 		&c.Agent.ID,
 		&c.Agent.Name,
 	)
@@ -344,32 +679,43 @@ func (db *DB) GetCheckById(ID int) (CheckModel, error) {
 	if err != nil {
 		return c, err
 	}
+	c.Check.AlertAfter = alertAfter
+	c.Check.RecoverAfter = recoverAfter
+	c.Retired = timeOrZero(retiredAt)
 
 	return c, nil
 }
 
 func (db *DB) GetNewerResults(resultID int) ([]ResultModel, error) {
-	rows, err := db.Query(
-		"SELECT id, status, status_msg, timestamp FROM results WHERE id > ?", resultID,
-	)
+	rows, err := db.Query("SELECT "+resultColumns+" FROM results WHERE id > ?", resultID)
 	if err != nil {
 		return nil, err
 	}
+	return scanResults(rows)
+}
+
+// The columns read by scanResults
+const resultColumns = "id, check_id, status, status_msg, timestamp, last_timestamp, count"
+
+// scanResults reads and closes rows of resultColumns
+func scanResults(rows *sql.Rows) ([]ResultModel, error) {
 	defer rows.Close()
 
 	results := []ResultModel{}
-
 	for rows.Next() {
-		var res ResultModel
-		var timestamp int64
-		err := rows.Scan(&res.ID, &res.Status, &res.Msg, &timestamp)
+		var (
+			res             ResultModel
+			timestamp, last int64
+		)
+		err := rows.Scan(&res.ID, &res.CheckID, &res.Status, &res.Msg, &timestamp, &last, &res.Count)
 		if err != nil {
 			return nil, err
 		}
 		res.Timestamp = time.Unix(timestamp, 0)
+		res.Last = timeOrZero(last)
 		results = append(results, res)
 	}
-	return results, nil
+	return results, rows.Err()
 }
 
 // Returns "" if there are no notified statuses
@@ -389,14 +735,167 @@ func (db *DB) LastNotification(checkID int) (string, error) {
 	}
 }
 
+// AddNotification records that a status change of a check has been notified
 func (db *DB) AddNotification(checkID int, status string) error {
+	return db.AddFollowUpNotification(checkID, status, "")
+}
+
+// AddFollowUpNotification records a notification of the kind "reminder" or "escalation:..." about the current status.
+// The empty kind is a status change.
+func (db *DB) AddFollowUpNotification(checkID int, status string, kind string) error {
 	_, err := db.Exec(
 		`INSERT INTO notifications
-		(check_id, status)
-		VALUES (?, ?)`,
-		checkID, status)
+		(check_id, status, timestamp, kind)
+		VALUES (?, ?, ?, ?)`,
+		checkID, status, time.Now().Unix(), kind)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// CurrentNotifications returns the last status change notification of a check followed by the
+// follow up notifications after it, oldest first. Timestamps are zero for notifications made before
+// we recorded them.
+func (db *DB) CurrentNotifications(checkID int) ([]NotificationModel, error) {
+	rows, err := db.Query(
+		`SELECT id, status, kind, timestamp FROM notifications
+		WHERE check_id = ? AND id >= (SELECT COALESCE(MAX(id), 0) FROM notifications WHERE check_id = ? AND kind = '')
+		ORDER BY id`,
+		checkID, checkID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []NotificationModel{}
+	for rows.Next() {
+		n := NotificationModel{CheckID: checkID}
+		var timestamp int64
+		err := rows.Scan(&n.ID, &n.Status, &n.Kind, &timestamp)
+		if err != nil {
+			return nil, err
+		}
+		n.Timestamp = timeOrZero(timestamp)
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+func (db *DB) GetAgents() ([]AgentModel, error) {
+	rows, err := db.Query("SELECT id, name FROM agents ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	agents := []AgentModel{}
+	for rows.Next() {
+		var a AgentModel
+		err := rows.Scan(&a.ID, &a.Name)
+		if err != nil {
+			return nil, err
+		}
+		agents = append(agents, a)
+	}
+	return agents, rows.Err()
+}
+
+// GetResults returns the results of a check since a given time, oldest first
+func (db *DB) GetResults(checkID int, since time.Time) ([]ResultModel, error) {
+	rows, err := db.Query(
+		"SELECT "+resultColumns+" FROM results WHERE check_id = ? AND timestamp >= ? ORDER BY timestamp, id",
+		checkID, since.Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanResults(rows)
+}
+
+// LastDigestRun returns when the named digest was last sent, or the zero time if never
+func (db *DB) LastDigestRun(name string) (time.Time, error) {
+	var lastRun int64
+	err := db.QueryRow("SELECT last_run FROM digest_runs WHERE name = ?", name).Scan(&lastRun)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(lastRun, 0), nil
+}
+
+func (db *DB) SetDigestRun(name string, t time.Time) error {
+	_, err := db.Exec(
+		"INSERT INTO digest_runs (name, last_run) VALUES (?, ?) ON CONFLICT(name) DO UPDATE SET last_run = ?",
+		name, t.Unix(), t.Unix(),
+	)
+	return err
+}
+
+// GetPeriodResults returns the results of a check between from and to, oldest first, preceded by
+// the last result before from if there is one
+func (db *DB) GetPeriodResults(checkID int, from, to time.Time) ([]ResultModel, error) {
+	rows, err := db.Query(
+		`SELECT `+resultColumns+` FROM results WHERE check_id = ? AND timestamp < ? AND timestamp >= (
+			SELECT COALESCE(MAX(timestamp), 0) FROM results WHERE check_id = ? AND timestamp < ?
+		)
+		ORDER BY timestamp, id`,
+		checkID, to.Unix(), checkID, from.Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	results, err := scanResults(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// Several results can share the timestamp before from, only keep the last of them
+	first := 0
+	for i := 1; i < len(results) && results[i].Timestamp.Before(from); i++ {
+		first = i
+	}
+	return results[first:], nil
+}
+
+// LastResultTimes returns the time of the last result of each check of an agent that has results
+func (db *DB) LastResultTimes(agent AgentModel) (map[int]time.Time, error) {
+	rows, err := db.Query(
+		`SELECT c.id, MAX(MAX(r.timestamp, r.last_timestamp)) FROM checks c
+		JOIN results r ON r.check_id = c.id
+		WHERE c.agent_id = ?
+		GROUP BY c.id`,
+		agent.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	times := map[int]time.Time{}
+	for rows.Next() {
+		var (
+			id        int
+			timestamp int64
+		)
+		if err := rows.Scan(&id, &timestamp); err != nil {
+			return nil, err
+		}
+		times[id] = time.Unix(timestamp, 0)
+	}
+	return times, rows.Err()
+}
+
+// GetRecentResults returns the last n results of a check, newest first
+func (db *DB) GetRecentResults(checkID int, n int) ([]ResultModel, error) {
+	rows, err := db.Query(
+		"SELECT "+resultColumns+" FROM results WHERE check_id = ? ORDER BY timestamp DESC, id DESC LIMIT ?",
+		checkID, n,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanResults(rows)
+}
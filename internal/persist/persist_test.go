@@ -2,7 +2,9 @@ package persist
 
 import (
 	"testing"
+	"time"
 
+	"github.com/rymdhund/whazza/internal/base"
 	"github.com/rymdhund/whazza/internal/chk"
 )
 
@@ -36,3 +38,138 @@ func TestCreateCheck(t *testing.T) {
 		t.Errorf("%v != %v", checkModel.Check, cm.Check)
 	}
 }
+
+func TestRegisterCheckThresholds(t *testing.T) {
+	db, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := AgentModel{1, "agent"}
+	if err = db.SaveAgent(agent.Name, ""); err != nil {
+		t.Fatal(err)
+	}
+	check, err := chk.New("http-up", "ns", 3, []byte(`{"host":"example.com"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm, err := db.RegisterCheck(agent, check)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check.AlertAfter = 3
+	check.RecoverAfter = 2
+	cm2, err := db.RegisterCheck(agent, check)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cm2.ID != cm.ID {
+		t.Fatalf("Expected same check, got %d and %d", cm.ID, cm2.ID)
+	}
+
+	checkModel, err := db.GetCheckById(cm.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkModel.Check.AlertAfter != 3 || checkModel.Check.RecoverAfter != 2 {
+		t.Errorf("Thresholds not saved: %+v", checkModel.Check)
+	}
+
+	// Init is run on every start and must work on an existing db
+	if err = db.Init(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetResults(t *testing.T) {
+	db, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.SaveAgent("agent", ""); err != nil {
+		t.Fatal(err)
+	}
+	agents, err := db.GetAgents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(agents) != 1 || agents[0].Name != "agent" {
+		t.Fatalf("Unexpected agents: %v", agents)
+	}
+	check, err := chk.New("http-up", "ns", 3, []byte(`{"host":"example.com"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm, err := db.RegisterCheck(agents[0], check)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for i, status := range []string{"good", "fail", "good"} {
+		res := base.Result{Status: status, Timestamp: now.Add(time.Duration(i-2) * time.Hour)}
+		if _, err := db.AddResult(agents[0], cm, res); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := db.GetResults(cm.ID, now.Add(-90*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Status != "fail" || results[1].Status != "good" {
+		t.Fatalf("Unexpected results: %v", results)
+	}
+}
+
+func TestAgentChecksAndLastResultTimes(t *testing.T) {
+	db := mkTestDb(t)
+	for _, name := range []string{"agent", "other"} {
+		if err := db.SaveAgent(name, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	agent, other := AgentModel{1, "agent"}, AgentModel{2, "other"}
+	check, _ := chk.New("http-up", "ns", 60, []byte(`{"host":"example.com"}`))
+	idle, _ := chk.New("http-up", "ns", 60, []byte(`{"host":"example.org"}`))
+	cm, err := db.RegisterCheck(agent, check)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.RegisterCheck(agent, idle); err != nil {
+		t.Fatal(err)
+	}
+	otherCm, err := db.RegisterCheck(other, check)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(1600000000, 0)
+	for i := 0; i < 3; i++ {
+		if _, err := db.AddResult(agent, cm, base.Result{Status: "good", Timestamp: start.Add(time.Duration(i) * time.Minute)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.AddResult(other, otherCm, base.Result{Status: "good", Timestamp: start.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	checks, err := db.GetAgentChecks(agent)
+	if err != nil || len(checks) != 2 {
+		t.Fatalf("Expected 2 checks, got %v %v", checks, err)
+	}
+	times, err := db.LastResultTimes(agent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(times) != 1 || !times[cm.ID].Equal(start.Add(2*time.Minute)) {
+		t.Fatalf("Unexpected last result times %v", times)
+	}
+}
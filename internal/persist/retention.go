@@ -0,0 +1,100 @@
+package persist
+
+import (
+	"strings"
+	"time"
+)
+
+// Max number of ids in a single delete statement
+const deleteChunk = 500
+
+// CompactResults rolls up at most limit raw results of a check from before the given time, which is rounded
+// down to the hour. Consecutive results with the same status within the same hour are replaced by the first
+// of them, with its last timestamp and count set to cover the others. Status transitions are thus kept.
+// Returns the number of raw results processed, 0 when there is nothing left to compact.
+func (db *DB) CompactResults(checkID int, before time.Time, limit int) (int, error) {
+	before = before.Truncate(time.Hour)
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(
+		`SELECT id, status, timestamp FROM results
+		WHERE check_id = ? AND timestamp < ? AND last_timestamp = 0
+		ORDER BY timestamp, id LIMIT ?`,
+		checkID, before.Unix(), limit,
+	)
+	if err != nil {
+		return 0, err
+	}
+	type raw struct {
+		id        int64
+		status    string
+		timestamp int64
+	}
+	raws := []raw{}
+	for rows.Next() {
+		var r raw
+		if err := rows.Scan(&r.id, &r.status, &r.timestamp); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		raws = append(raws, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	deleted := []interface{}{}
+	for start := 0; start < len(raws); {
+		first := raws[start]
+		end := start + 1
+		for end < len(raws) && raws[end].status == first.status && raws[end].timestamp/3600 == first.timestamp/3600 {
+			deleted = append(deleted, raws[end].id)
+			end++
+		}
+		_, err := tx.Exec(
+			"UPDATE results SET last_timestamp = ?, count = ? WHERE id = ?",
+			raws[end-1].timestamp, end-start, first.id,
+		)
+		if err != nil {
+			return 0, err
+		}
+		start = end
+	}
+	for len(deleted) > 0 {
+		n := deleteChunk
+		if len(deleted) < n {
+			n = len(deleted)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+		_, err := tx.Exec("DELETE FROM results WHERE id IN ("+placeholders+")", deleted[:n]...)
+		if err != nil {
+			return 0, err
+		}
+		deleted = deleted[n:]
+	}
+	return len(raws), tx.Commit()
+}
+
+// PruneResults deletes at most limit results of a check from before the given time. Returns the number deleted.
+func (db *DB) PruneResults(checkID int, before time.Time, limit int) (int, error) {
+	res, err := db.Exec(
+		"DELETE FROM results WHERE id IN (SELECT id FROM results WHERE check_id = ? AND timestamp < ? LIMIT ?)",
+		checkID, before.Unix(), limit,
+	)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// PruneEvents deletes the events from before the given time
+func (db *DB) PruneEvents(before time.Time) error {
+	_, err := db.Exec("DELETE FROM events WHERE timestamp < ?", before.Unix())
+	return err
+}
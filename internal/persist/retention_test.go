@@ -0,0 +1,96 @@
+package persist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/base"
+	"github.com/rymdhund/whazza/internal/chk"
+)
+
+func TestCompactAndPruneResults(t *testing.T) {
+	db := mkTestDb(t)
+	if err := db.SaveAgent("agent", ""); err != nil {
+		t.Fatal(err)
+	}
+	agent := AgentModel{1, "agent"}
+	check, _ := chk.New("http-up", "web", 600, []byte(`{"host":"example.com"}`))
+	cm, err := db.RegisterCheck(agent, check)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Results every 10 minutes for 3 hours, failing from 01:30 to 01:50
+	start := time.Unix(1600000000, 0).Truncate(time.Hour)
+	for i := 0; i < 18; i++ {
+		status := "good"
+		if i >= 9 && i < 12 {
+			status = "fail"
+		}
+		_, err := db.AddResult(agent, cm, base.Result{Status: status, Timestamp: start.Add(time.Duration(i) * 10 * time.Minute)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Compact the first two hours in small batches. The cutoff is rounded down to the hour.
+	batches := 0
+	for {
+		n, err := db.CompactResults(cm.ID, start.Add(2*time.Hour+30*time.Minute), 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			break
+		}
+		batches++
+	}
+	if batches != 3 {
+		t.Errorf("Expected 3 batches, got %d", batches)
+	}
+
+	results, err := db.GetResults(cm.ID, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Batches split runs, but never across hours. The last hour is left raw.
+	expected := []struct {
+		status string
+		offset time.Duration
+		count  int
+	}{
+		{"good", 0, 4},
+		{"good", 40 * time.Minute, 2},
+		{"good", 60 * time.Minute, 2},
+		{"good", 80 * time.Minute, 1},
+		{"fail", 90 * time.Minute, 3},
+	}
+	if len(results) != len(expected)+6 {
+		t.Fatalf("Expected %d results, got %+v", len(expected)+6, results)
+	}
+	for i, e := range expected {
+		res := results[i]
+		if res.Status != e.status || !res.Timestamp.Equal(start.Add(e.offset)) || res.Count != e.count {
+			t.Errorf("Unexpected result %d: %+v", i, res)
+		}
+	}
+	if results[5].Count != 1 || !results[5].Last.IsZero() {
+		t.Errorf("Expected raw result, got %+v", results[5])
+	}
+	if last := results[4].LastTimestamp(); !last.Equal(start.Add(110 * time.Minute)) {
+		t.Errorf("Expected the fail rollup to last until 01:50, got %s", last)
+	}
+
+	overview, err := db.GetCheckOverview(cm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !overview.LastFail.Timestamp.Equal(start.Add(110 * time.Minute)) {
+		t.Errorf("Expected last fail at the end of the rollup, got %s", overview.LastFail.Timestamp)
+	}
+
+	n, err := db.PruneResults(cm.ID, start.Add(time.Hour), 100)
+	if err != nil || n != 2 {
+		t.Fatalf("Expected 2 pruned results, got %d %v", n, err)
+	}
+}
@@ -0,0 +1,88 @@
+package persist
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/chk"
+)
+
+// RetireCheck marks a check as no longer run, unless it already is retired. A retired check is restored
+// by results newer than its retirement.
+func (db *DB) RetireCheck(check CheckModel, user string, now time.Time) error {
+	return retireCheck(db, check, user, fmt.Sprintf("Retired %s", check.Check.Title()), now)
+}
+
+func retireCheck(db querier, check CheckModel, user, message string, now time.Time) error {
+	res, err := db.Exec("UPDATE checks SET retired_at = ? WHERE id = ? AND retired_at = 0", now.Unix(), check.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		// Already retired
+		return nil
+	}
+	return addCheckEvent(db, check, EventCheckRetired, user, message, now)
+}
+
+// RetireMissingChecks retires the active checks of an agent that are not among the checks the agent
+// currently runs. Checks are compared by type, namespace and checker settings. It returns the retired checks.
+func (db *DB) RetireMissingChecks(agent AgentModel, current []chk.Check, now time.Time) ([]CheckModel, error) {
+	checks, err := db.GetAgentChecks(agent)
+	if err != nil {
+		return nil, err
+	}
+
+	running := map[[3]string]bool{}
+	for _, c := range current {
+		running[[3]string{c.Type, c.Namespace, string(c.Checker.AsJson())}] = true
+	}
+	missing := []CheckModel{}
+	for _, c := range checks {
+		if !running[[3]string{c.Check.Type, c.Check.Namespace, string(c.Check.Checker.AsJson())}] {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) == 0 {
+		return missing, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	for _, c := range missing {
+		message := fmt.Sprintf("Retired %s, agent %s no longer runs it", c.Check.Title(), agent.Name)
+		err := retireCheck(tx, c, "", message, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return missing, tx.Commit()
+}
+
+// DeleteCheck removes a check with its results and notifications. Its events are kept.
+func (db *DB) DeleteCheck(check CheckModel, user string, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		"DELETE FROM results WHERE check_id = ?",
+		"DELETE FROM notifications WHERE check_id = ?",
+		"DELETE FROM checks WHERE id = ?",
+	} {
+		_, err := tx.Exec(query, check.ID)
+		if err != nil {
+			return err
+		}
+	}
+	err = addCheckEvent(tx, check, EventCheckDeleted, user, fmt.Sprintf("Deleted %s", check.Check.Title()), now)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
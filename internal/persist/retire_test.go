@@ -0,0 +1,107 @@
+package persist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/base"
+	"github.com/rymdhund/whazza/internal/chk"
+)
+
+func TestRetireMissingChecks(t *testing.T) {
+	db := mkTestDb(t)
+	if err := db.SaveAgent("agent", ""); err != nil {
+		t.Fatal(err)
+	}
+	agent := AgentModel{1, "agent"}
+	web, _ := chk.New("http-up", "ns", 60, []byte(`{"host":"example.com"}`))
+	old, _ := chk.New("http-up", "ns", 60, []byte(`{"host":"old.example.com"}`))
+	webModel, err := db.RegisterCheck(agent, web)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldModel, err := db.RegisterCheck(agent, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1600000000, 0)
+	if _, err := db.AddResult(agent, oldModel, base.Result{Status: "good", Timestamp: now.Add(-time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	retired, err := db.RetireMissingChecks(agent, []chk.Check{web}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retired) != 1 || retired[0].ID != oldModel.ID {
+		t.Fatalf("Expected the old check to be retired, got %+v", retired)
+	}
+	active, _ := db.GetChecks(false)
+	all, _ := db.GetChecks(true)
+	if len(active) != 1 || active[0].ID != webModel.ID || len(all) != 2 {
+		t.Fatalf("Unexpected checks %+v %+v", active, all)
+	}
+	overviews, _ := db.GetCheckOverviews(true)
+	for _, o := range overviews {
+		if o.CheckModel.ID == oldModel.ID && (o.Result.Status == "expired" || !o.CheckModel.Retired.Equal(now)) {
+			t.Fatalf("Unexpected retired overview %+v", o)
+		}
+	}
+
+	// Results from before the retirement don't restore the check, newer results do
+	oldModel, _ = db.RegisterCheck(agent, old)
+	if _, err := db.AddResult(agent, oldModel, base.Result{Status: "good", Timestamp: now.Add(-time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if active, _ := db.GetChecks(false); len(active) != 1 {
+		t.Fatalf("Expected the check to stay retired, got %+v", active)
+	}
+	oldModel, _ = db.RegisterCheck(agent, old)
+	if _, err := db.AddResult(agent, oldModel, base.Result{Status: "good", Timestamp: now.Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if active, _ := db.GetChecks(false); len(active) != 2 {
+		t.Fatalf("Expected the check to be restored, got %+v", active)
+	}
+	events, _ := db.GetEvents(EventFilter{CheckID: oldModel.ID, Kind: EventCheckRestored})
+	if len(events) != 1 || !events[0].Timestamp.Equal(now.Add(time.Minute)) {
+		t.Fatalf("Unexpected events %+v", events)
+	}
+}
+
+func TestDeleteCheck(t *testing.T) {
+	db := mkTestDb(t)
+	if err := db.SaveAgent("agent", ""); err != nil {
+		t.Fatal(err)
+	}
+	agent := AgentModel{1, "agent"}
+	check, _ := chk.New("http-up", "ns", 60, []byte(`{"host":"example.com"}`))
+	cm, err := db.RegisterCheck(agent, check)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1600000000, 0)
+	if _, err := db.AddResult(agent, cm, base.Result{Status: "fail", Timestamp: now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddNotification(cm.ID, "fail"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.DeleteCheck(cm, "alice", now); err != nil {
+		t.Fatal(err)
+	}
+	if checks, _ := db.GetChecks(true); len(checks) != 0 {
+		t.Fatalf("Expected no checks, got %+v", checks)
+	}
+	if results, _ := db.GetResults(cm.ID, time.Time{}); len(results) != 0 {
+		t.Fatalf("Expected no results, got %+v", results)
+	}
+	if status, _ := db.LastNotification(cm.ID); status != "" {
+		t.Fatalf("Expected no notifications, got %s", status)
+	}
+	events, _ := db.GetEvents(EventFilter{Kind: EventCheckDeleted})
+	if len(events) != 1 || events[0].User != "alice" {
+		t.Fatalf("Unexpected events %+v", events)
+	}
+}
@@ -0,0 +1,118 @@
+package persist
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrSilenceNotFound = errors.New("No such silence")
+
+// unixOrZero stores zero times as 0 instead of a negative unix time
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func timeOrZero(secs int64) time.Time {
+	if secs == 0 {
+		return time.Time{}
+	}
+	return time.Unix(secs, 0)
+}
+
+func (db *DB) AddSilence(s SilenceModel) (SilenceModel, error) {
+	if err := s.Validate(); err != nil {
+		return SilenceModel{}, err
+	}
+	res, err := db.Exec(
+		`INSERT INTO silences
+		(namespace, agent, check_id, starts, ends, schedule, duration, comment, created_by, created)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.Namespace, s.Agent, s.CheckID, unixOrZero(s.Start), unixOrZero(s.End), s.Schedule,
+		int64(s.Duration.Seconds()), s.Comment, s.CreatedBy, unixOrZero(s.Created),
+	)
+	if err != nil {
+		return SilenceModel{}, err
+	}
+	id, _ := res.LastInsertId()
+	s.ID = int(id)
+
+	err = db.AddEvent(EventModel{
+		Timestamp: s.Created,
+		Kind:      EventSilenceAdded,
+		CheckID:   s.CheckID,
+		Agent:     s.Agent,
+		Namespace: s.Namespace,
+		User:      s.CreatedBy,
+		Message:   fmt.Sprintf("Silence %d for %s: %s", s.ID, s.Scope(), s.Comment),
+	})
+	if err != nil {
+		return SilenceModel{}, err
+	}
+	return s, nil
+}
+
+// GetSilences returns the silences that have not expired at now, or all silences if all is true
+func (db *DB) GetSilences(all bool, now time.Time) ([]SilenceModel, error) {
+	rows, err := db.Query(
+		`SELECT id, namespace, agent, check_id, starts, ends, schedule, duration, comment, created_by, created
+		FROM silences WHERE ? OR ends = 0 OR ends > ? ORDER BY id`,
+		all, now.Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	silences := []SilenceModel{}
+	for rows.Next() {
+		var (
+			s                             SilenceModel
+			start, end, duration, created int64
+		)
+		err := rows.Scan(&s.ID, &s.Namespace, &s.Agent, &s.CheckID, &start, &end, &s.Schedule, &duration, &s.Comment, &s.CreatedBy, &created)
+		if err != nil {
+			return nil, err
+		}
+		s.Start = timeOrZero(start)
+		s.End = timeOrZero(end)
+		s.Duration = time.Duration(duration) * time.Second
+		s.Created = timeOrZero(created)
+		silences = append(silences, s)
+	}
+	return silences, rows.Err()
+}
+
+// ActiveSilences returns the silences that silence the check at the given time
+func (db *DB) ActiveSilences(check CheckModel, t time.Time) ([]SilenceModel, error) {
+	silences, err := db.GetSilences(false, t)
+	if err != nil {
+		return nil, err
+	}
+	active := []SilenceModel{}
+	for _, s := range silences {
+		if s.Matches(check) && s.ActiveAt(t) {
+			active = append(active, s)
+		}
+	}
+	return active, nil
+}
+
+// ExpireSilence ends a silence at the given time on behalf of user
+func (db *DB) ExpireSilence(id int, user string, now time.Time) error {
+	res, err := db.Exec("UPDATE silences SET ends = ? WHERE id = ? AND (ends = 0 OR ends > ?)", now.Unix(), id, now.Unix())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrSilenceNotFound
+	}
+	return db.AddEvent(EventModel{Timestamp: now, Kind: EventSilenceExpired, User: user, Message: fmt.Sprintf("Silence %d expired", id)})
+}
@@ -0,0 +1,70 @@
+package persist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/chk"
+)
+
+func TestSilences(t *testing.T) {
+	db := mkTestDb(t)
+	now := time.Unix(1641092400, 0) // sunday 2022-01-02 03:00 UTC
+
+	if _, err := db.AddSilence(SilenceModel{Start: now, End: now.Add(time.Hour)}); err == nil {
+		t.Fatal("Expected error for silence without scope")
+	}
+	if _, err := db.AddSilence(SilenceModel{Namespace: "db:*", Start: now}); err == nil {
+		t.Fatal("Expected error for silence without end")
+	}
+	if _, err := db.AddSilence(SilenceModel{Namespace: "db:*", Schedule: "0 3 * *"}); err == nil {
+		t.Fatal("Expected error for invalid schedule")
+	}
+
+	fixed, err := db.AddSilence(SilenceModel{Namespace: "db:*", Start: now.Add(-time.Minute), End: now.Add(time.Hour), Comment: "upgrade", CreatedBy: "alice", Created: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.AddSilence(SilenceModel{Agent: "backup", Schedule: "0 3 * * 0", Duration: 2 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check, _ := chk.New("tcp-port", "db:postgres", 60, []byte(`{"host": "db", "port": 5432}`))
+	dbCheck := CheckModel{ID: 1, Check: check, Agent: AgentModel{Name: "web"}}
+	backupCheck := CheckModel{ID: 2, Check: check, Agent: AgentModel{Name: "backup"}}
+	backupCheck.Check.Namespace = "backup"
+
+	active, err := db.ActiveSilences(dbCheck, now)
+	if err != nil || len(active) != 1 || active[0].ID != fixed.ID || active[0].Comment != "upgrade" {
+		t.Fatalf("Expected fixed silence to be active: %v %v", active, err)
+	}
+	active, err = db.ActiveSilences(backupCheck, now.Add(time.Hour))
+	if err != nil || len(active) != 1 {
+		t.Fatalf("Expected scheduled silence to be active: %v %v", active, err)
+	}
+	active, err = db.ActiveSilences(backupCheck, now.Add(3*time.Hour))
+	if err != nil || len(active) != 0 {
+		t.Fatalf("Expected scheduled silence to be inactive: %v %v", active, err)
+	}
+
+	if err := db.ExpireSilence(fixed.ID, "test", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.ExpireSilence(fixed.ID, "test", now); err != ErrSilenceNotFound {
+		t.Fatalf("Expected ErrSilenceNotFound, got %v", err)
+	}
+	active, err = db.ActiveSilences(dbCheck, now)
+	if err != nil || len(active) != 0 {
+		t.Fatalf("Expected no active silences after expiry: %v %v", active, err)
+	}
+
+	silences, err := db.GetSilences(false, now)
+	if err != nil || len(silences) != 1 {
+		t.Fatalf("Expected one unexpired silence: %v %v", silences, err)
+	}
+	silences, err = db.GetSilences(true, now)
+	if err != nil || len(silences) != 2 {
+		t.Fatalf("Expected two silences: %v %v", silences, err)
+	}
+}
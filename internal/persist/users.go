@@ -0,0 +1,224 @@
+package persist
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/password"
+	"github.com/rymdhund/whazza/internal/sectoken"
+)
+
+var (
+	ErrUserNotFound     = errors.New("No such user")
+	ErrApiTokenNotFound = errors.New("No such api token")
+)
+
+func (db *DB) AddUser(name, passwordHash, role string) (UserModel, error) {
+	if !ValidRole(role) {
+		return UserModel{}, fmt.Errorf("Invalid role: %s", role)
+	}
+	res, err := db.Exec(
+		"INSERT INTO users (name, password_hash, role) VALUES (?, ?, ?)",
+		name, passwordHash, role)
+	if err != nil {
+		return UserModel{}, err
+	}
+	id, _ := res.LastInsertId()
+	return UserModel{int(id), name, role}, nil
+}
+
+func (db *DB) GetUser(name string) (UserModel, error) {
+	u := UserModel{Name: name}
+	err := db.QueryRow("SELECT id, role FROM users WHERE name = ?", name).Scan(&u.ID, &u.Role)
+	if err == sql.ErrNoRows {
+		return UserModel{}, ErrUserNotFound
+	}
+	return u, err
+}
+
+func (db *DB) GetUsers() ([]UserModel, error) {
+	rows, err := db.Query("SELECT id, name, role FROM users ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []UserModel{}
+	for rows.Next() {
+		var u UserModel
+		if err := rows.Scan(&u.ID, &u.Name, &u.Role); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// RemoveUser removes the user together with its sessions and api tokens
+func (db *DB) RemoveUser(name string) error {
+	user, err := db.GetUser(name)
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, q := range []string{
+		"DELETE FROM sessions WHERE user_id = ?",
+		"DELETE FROM api_tokens WHERE user_id = ?",
+		"DELETE FROM users WHERE id = ?",
+	} {
+		if _, err := tx.Exec(q, user.ID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SetPassword changes the password hash of a user and logs out all its sessions
+func (db *DB) SetPassword(name, passwordHash string) error {
+	user, err := db.GetUser(name)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("UPDATE users SET password_hash = ? WHERE id = ?", passwordHash, user.ID)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("DELETE FROM sessions WHERE user_id = ?", user.ID)
+	return err
+}
+
+func (db *DB) AuthenticateUser(name, pw string) (UserModel, bool, error) {
+	var (
+		u    = UserModel{Name: name}
+		hash string
+	)
+	err := db.QueryRow("SELECT id, role, password_hash FROM users WHERE name = ?", name).Scan(&u.ID, &u.Role, &hash)
+	switch {
+	case err == sql.ErrNoRows:
+		// Take as long as for a wrong password so that the response time doesn't reveal who has an account
+		password.VerifyNothing(pw)
+		return UserModel{}, false, nil
+	case err != nil:
+		return UserModel{}, false, err
+	}
+	ok, err := password.Verify(pw, hash)
+	if err != nil || !ok {
+		return UserModel{}, false, err
+	}
+	return u, true, nil
+}
+
+// CreateSession stores a new session for the user and returns its token
+func (db *DB) CreateSession(user UserModel, expires time.Time) (sectoken.SecToken, error) {
+	// Clean up old sessions while we're at it
+	_, err := db.Exec("DELETE FROM sessions WHERE expires < ?", time.Now().Unix())
+	if err != nil {
+		return "", err
+	}
+
+	token := sectoken.New()
+	_, err = db.Exec(
+		"INSERT INTO sessions (user_id, token_hash, expires) VALUES (?, ?, ?)",
+		user.ID, token.Hash(), expires.Unix())
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (db *DB) AuthenticateSession(token sectoken.SecToken) (UserModel, bool, error) {
+	var u UserModel
+	err := db.QueryRow(
+		`SELECT u.id, u.name, u.role FROM sessions s
+		JOIN users u ON s.user_id = u.id
+		WHERE s.token_hash = ? AND s.expires > ?`,
+		token.Hash(), time.Now().Unix(),
+	).Scan(&u.ID, &u.Name, &u.Role)
+	switch {
+	case err == sql.ErrNoRows:
+		return UserModel{}, false, nil
+	case err != nil:
+		return UserModel{}, false, err
+	default:
+		return u, true, nil
+	}
+}
+
+func (db *DB) DeleteSession(token sectoken.SecToken) error {
+	_, err := db.Exec("DELETE FROM sessions WHERE token_hash = ?", token.Hash())
+	return err
+}
+
+// CreateApiToken stores a new named api token for the user and returns it
+func (db *DB) CreateApiToken(user UserModel, name string) (sectoken.SecToken, error) {
+	token := sectoken.New()
+	_, err := db.Exec(
+		"INSERT INTO api_tokens (user_id, name, token_hash, created) VALUES (?, ?, ?, ?)",
+		user.ID, name, token.Hash(), time.Now().Unix())
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (db *DB) GetApiTokens(user UserModel) ([]ApiTokenModel, error) {
+	rows, err := db.Query("SELECT id, name, created FROM api_tokens WHERE user_id = ? ORDER BY id", user.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []ApiTokenModel{}
+	for rows.Next() {
+		var (
+			t       ApiTokenModel
+			created int64
+		)
+		if err := rows.Scan(&t.ID, &t.Name, &created); err != nil {
+			return nil, err
+		}
+		t.Created = time.Unix(created, 0)
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeApiToken removes an api token of the user so that it can't be used anymore
+func (db *DB) RevokeApiToken(user UserModel, id int) error {
+	res, err := db.Exec("DELETE FROM api_tokens WHERE id = ? AND user_id = ?", id, user.ID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrApiTokenNotFound
+	}
+	return nil
+}
+
+func (db *DB) AuthenticateApiToken(token sectoken.SecToken) (UserModel, bool, error) {
+	var u UserModel
+	err := db.QueryRow(
+		`SELECT u.id, u.name, u.role FROM api_tokens t
+		JOIN users u ON t.user_id = u.id
+		WHERE t.token_hash = ?`,
+		token.Hash(),
+	).Scan(&u.ID, &u.Name, &u.Role)
+	switch {
+	case err == sql.ErrNoRows:
+		return UserModel{}, false, nil
+	case err != nil:
+		return UserModel{}, false, err
+	default:
+		return u, true, nil
+	}
+}
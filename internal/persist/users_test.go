@@ -0,0 +1,137 @@
+package persist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/password"
+)
+
+func mkTestDb(t *testing.T) *DB {
+	db, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestUsers(t *testing.T) {
+	db := mkTestDb(t)
+
+	_, err := db.AddUser("alice", password.Hash("secret"), "superuser")
+	if err == nil {
+		t.Fatal("Expected error for invalid role")
+	}
+	alice, err := db.AddUser("alice", password.Hash("secret"), RoleOperator)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, ok, err := db.AuthenticateUser("alice", "secret")
+	if err != nil || !ok || user.ID != alice.ID || user.Role != RoleOperator {
+		t.Fatalf("Expected alice to authenticate: %v %v %v", user, ok, err)
+	}
+	_, ok, err = db.AuthenticateUser("alice", "wrong")
+	if err != nil || ok {
+		t.Fatal("Expected wrong password to fail")
+	}
+	_, ok, err = db.AuthenticateUser("bob", "secret")
+	if err != nil || ok {
+		t.Fatal("Expected unknown user to fail")
+	}
+
+	session, err := db.CreateSession(alice, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := db.AuthenticateSession(session); !ok {
+		t.Fatal("Expected session to be valid")
+	}
+	expired, err := db.CreateSession(alice, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := db.AuthenticateSession(expired); ok {
+		t.Fatal("Expected expired session to be invalid")
+	}
+
+	token, err := db.CreateApiToken(alice, "grafana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := db.AuthenticateApiToken(token); !ok {
+		t.Fatal("Expected api token to be valid")
+	}
+	revoked, err := db.CreateApiToken(alice, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := db.GetApiTokens(alice)
+	if err != nil || len(tokens) != 2 || tokens[0].Name != "grafana" || tokens[1].Name != "old" {
+		t.Fatalf("Unexpected tokens %+v %v", tokens, err)
+	}
+	if err := db.RevokeApiToken(UserModel{ID: alice.ID + 1}, tokens[1].ID); err != ErrApiTokenNotFound {
+		t.Fatalf("Expected ErrApiTokenNotFound for another user, got %v", err)
+	}
+	if err := db.RevokeApiToken(alice, tokens[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := db.AuthenticateApiToken(revoked); ok {
+		t.Fatal("Expected revoked api token to be invalid")
+	}
+	if _, ok, _ := db.AuthenticateApiToken(token); !ok {
+		t.Fatal("Expected other api token to stay valid")
+	}
+
+	// Changing password logs out sessions
+	if err := db.SetPassword("alice", password.Hash("new")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := db.AuthenticateSession(session); ok {
+		t.Fatal("Expected session to be logged out")
+	}
+	if _, ok, _ := db.AuthenticateUser("alice", "new"); !ok {
+		t.Fatal("Expected new password to work")
+	}
+
+	if err := db.RemoveUser("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := db.AuthenticateApiToken(token); ok {
+		t.Fatal("Expected api token to be removed")
+	}
+	if err := db.RemoveUser("alice"); err != ErrUserNotFound {
+		t.Fatalf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	operator := UserModel{Role: RoleOperator}
+	viewer := UserModel{Role: RoleViewer}
+	if !operator.HasRole(RoleOperator) || !operator.HasRole(RoleViewer) {
+		t.Error("Expected operator to have lower roles")
+	}
+	if viewer.HasRole(RoleOperator) {
+		t.Error("Expected viewer to not be operator")
+	}
+	if (UserModel{}).HasRole(RoleViewer) {
+		t.Error("Expected user without role to have no roles")
+	}
+}
+
+func TestAdminsBecomeOperators(t *testing.T) {
+	db := mkTestDb(t)
+	if _, err := db.Exec("INSERT INTO users (name, password_hash, role) VALUES ('alice', '', 'admin')"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := db.GetUser("alice"); err != nil || u.Role != RoleOperator {
+		t.Fatalf("Expected alice to be operator, got %+v %v", u, err)
+	}
+}
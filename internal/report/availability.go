@@ -0,0 +1,119 @@
+// Package report computes summaries of check results over a period, like availability and flapping
+package report
+
+import (
+	"time"
+
+	"github.com/rymdhund/whazza/internal/chk"
+	"github.com/rymdhund/whazza/internal/persist"
+)
+
+// Availability is how long a check was up, down or in an unknown state during a period
+type Availability struct {
+	Up      time.Duration
+	Down    time.Duration
+	Unknown time.Duration
+}
+
+func (a Availability) Add(b Availability) Availability {
+	return Availability{a.Up + b.Up, a.Down + b.Down, a.Unknown + b.Unknown}
+}
+
+// Percent is the share of the known time that the check was up, 100 if nothing is known
+func (a Availability) Percent() float64 {
+	if a.Up+a.Down == 0 {
+		return 100
+	}
+	return float64(a.Up) * 100 / float64(a.Up+a.Down)
+}
+
+// Stats is the availability of a check or group of checks with the number of incidents, ie separate
+// periods of downtime
+type Stats struct {
+	Availability
+	Incidents int
+}
+
+func (s Stats) Add(b Stats) Stats {
+	return Stats{s.Availability.Add(b.Availability), s.Incidents + b.Incidents}
+}
+
+// MTTR is the mean time to recovery, the average length of an incident
+func (s Stats) MTTR() time.Duration {
+	if s.Incidents == 0 {
+		return 0
+	}
+	return s.Down / time.Duration(s.Incidents)
+}
+
+// MTBF is the mean time between failures, the up time per incident
+func (s Stats) MTBF() time.Duration {
+	if s.Incidents == 0 {
+		return 0
+	}
+	return s.Up / time.Duration(s.Incidents)
+}
+
+// isUp returns true for statuses where the checked thing works
+func isUp(status string) bool {
+	return status == "good" || status == "warning"
+}
+
+// CheckStats computes the availability of a check from results oldest first. The first result may be
+// from before the period and gives the status at its start. A status lasts until the next result or until
+// the check expires. Errors and the time before the first result count as unknown. Expired time counts as
+// unknown or, with expiredAsDown, as down.
+//
+// An incident starts when the check goes down after being up or at the start of the period. Unknown time
+// in between does not end an incident.
+func CheckStats(check chk.Check, results []persist.ResultModel, from, to time.Time, expiredAsDown bool) Stats {
+	var s Stats
+	wasDown := false
+	add := func(status string, start, end time.Time) {
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if !end.After(start) {
+			return
+		}
+		d := end.Sub(start)
+		switch {
+		case isUp(status):
+			s.Up += d
+			wasDown = false
+		case status == "fail" || (status == "expired" && expiredAsDown):
+			s.Down += d
+			if !wasDown {
+				s.Incidents++
+			}
+			wasDown = true
+		default:
+			s.Unknown += d
+		}
+	}
+
+	if len(results) == 0 {
+		add("", from, to)
+		return s
+	}
+	// Nothing is known before the first result
+	add("", from, results[0].Timestamp)
+	for i, res := range results {
+		end := to
+		if i+1 < len(results) {
+			end = results[i+1].Timestamp
+		}
+		// Rolled up results stand for all results until their last timestamp
+		expires := res.LastTimestamp().Add(check.ExpiryLimit())
+		if expires.Before(end) {
+			add(res.Status, res.Timestamp, expires)
+			add("expired", expires, end)
+		} else {
+			add(res.Status, res.Timestamp, end)
+		}
+	}
+	return s
+}
@@ -0,0 +1,119 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/chk"
+	"github.com/rymdhund/whazza/internal/persist"
+)
+
+type DigestCheck struct {
+	Overview    persist.CheckOverview
+	FlapPercent float64
+}
+
+type NamespaceAvailability struct {
+	Namespace string
+	Availability
+}
+
+// Digest summarizes the state of the hub and the results of a period
+type Digest struct {
+	From          time.Time
+	To            time.Time
+	Failing       []DigestCheck
+	Expired       []DigestCheck
+	Flapping      []DigestCheck
+	ExpiringCerts []DigestCheck
+	Namespaces    []NamespaceAvailability
+}
+
+// BuildDigest computes a digest for the period. Checks count as flapping if their results reached the flap threshold.
+func BuildDigest(db *persist.DB, from, to time.Time, opts Options) (Digest, error) {
+	d := Digest{From: from, To: to}
+	overviews, err := db.GetCheckOverviews(false)
+	if err != nil {
+		return d, err
+	}
+	sort.Slice(overviews, func(i, j int) bool {
+		ci, cj := overviews[i].CheckModel.Check, overviews[j].CheckModel.Check
+		if ci.Namespace != cj.Namespace {
+			return ci.Namespace < cj.Namespace
+		}
+		return ci.Title() < cj.Title()
+	})
+
+	namespaces := map[string]Availability{}
+	for _, o := range overviews {
+		switch o.Result.Status {
+		case "fail", "error":
+			d.Failing = append(d.Failing, DigestCheck{Overview: o})
+		case "expired":
+			d.Expired = append(d.Expired, DigestCheck{Overview: o})
+		}
+		if o.CheckModel.Check.Type == "cert" && o.Result.Status != "good" && chk.IsCertExpiresMsg(o.Result.Msg) {
+			d.ExpiringCerts = append(d.ExpiringCerts, DigestCheck{Overview: o})
+		}
+
+		results, err := db.GetPeriodResults(o.CheckModel.ID, from, to)
+		if err != nil {
+			return d, err
+		}
+		if p := MaxFlapPercent(results); opts.FlapThreshold > 0 && p >= opts.FlapThreshold {
+			d.Flapping = append(d.Flapping, DigestCheck{Overview: o, FlapPercent: p})
+		}
+		ns := o.CheckModel.Check.Namespace
+		namespaces[ns] = namespaces[ns].Add(CheckStats(o.CheckModel.Check, results, from, to, opts.ExpiredAsDown).Availability)
+	}
+
+	for ns, a := range namespaces {
+		d.Namespaces = append(d.Namespaces, NamespaceAvailability{ns, a})
+	}
+	sort.Slice(d.Namespaces, func(i, j int) bool { return d.Namespaces[i].Namespace < d.Namespaces[j].Namespace })
+	return d, nil
+}
+
+func (d Digest) Subject() string {
+	return fmt.Sprintf("Whazza digest %s - %s: %d failing, %d expired",
+		d.From.Format("2006-01-02"), d.To.Format("2006-01-02"), len(d.Failing), len(d.Expired))
+}
+
+func (d Digest) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Whazza digest for %s to %s\n", d.From.Format("2006-01-02 15:04"), d.To.Format("2006-01-02 15:04"))
+
+	section := func(title string, checks []DigestCheck, line func(DigestCheck) string) {
+		fmt.Fprintf(&b, "\n%s (%d):\n", title, len(checks))
+		if len(checks) == 0 {
+			b.WriteString("  none\n")
+		}
+		for _, c := range checks {
+			check := c.Overview.CheckModel
+			fmt.Fprintf(&b, "  [%s] %s on %s%s\n", check.Check.Namespace, check.Check.Title(), check.Agent.Name, line(c))
+		}
+	}
+	section("Failing checks", d.Failing, func(c DigestCheck) string {
+		return fmt.Sprintf(" | %s | %s", c.Overview.Result.Status, c.Overview.Result.Msg)
+	})
+	section("Expired checks", d.Expired, func(c DigestCheck) string {
+		return fmt.Sprintf(" | last received %s", c.Overview.LastReceived.Timestamp.Format("2006-01-02 15:04"))
+	})
+	section("Flapping checks", d.Flapping, func(c DigestCheck) string {
+		return fmt.Sprintf(" | up to %.0f%% state changes", c.FlapPercent)
+	})
+	section("Certs expiring soon", d.ExpiringCerts, func(c DigestCheck) string {
+		return fmt.Sprintf(" | %s", c.Overview.Result.Msg)
+	})
+
+	b.WriteString("\nUptime per namespace:\n")
+	if len(d.Namespaces) == 0 {
+		b.WriteString("  none\n")
+	}
+	for _, ns := range d.Namespaces {
+		fmt.Fprintf(&b, "  %s %.2f%%\n", ns.Namespace, ns.Percent())
+	}
+	return b.String()
+}
@@ -0,0 +1,37 @@
+package report
+
+import (
+	"github.com/rymdhund/whazza/internal/persist"
+)
+
+// Number of results used for flap detection
+const FlapWindow = 21
+
+// FlapPercent calculates the weighted percent of state changes in the results, newest first.
+// Like Nagios, newer changes weigh more (1.18) than older changes (0.8).
+func FlapPercent(recent []persist.ResultModel) float64 {
+	changes := 0.0
+	for j := 0; j+1 < len(recent) && j < FlapWindow-1; j++ {
+		if recent[j].Status != recent[j+1].Status {
+			changes += 0.8 + 0.02*float64(FlapWindow-2-j)
+		}
+	}
+	return changes * 100 / (FlapWindow - 1)
+}
+
+// MaxFlapPercent is the highest flap percent of any window of results, given oldest first
+func MaxFlapPercent(results []persist.ResultModel) float64 {
+	window := make([]persist.ResultModel, 0, FlapWindow)
+	max := 0.0
+	for i := len(results) - 1; i >= 0; i-- {
+		// Newest first windows ending at result i
+		window = window[:0]
+		for j := i; j >= 0 && len(window) < FlapWindow; j-- {
+			window = append(window, results[j])
+		}
+		if p := FlapPercent(window); p > max {
+			max = p
+		}
+	}
+	return max
+}
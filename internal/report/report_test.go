@@ -0,0 +1,227 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/base"
+	"github.com/rymdhund/whazza/internal/chk"
+	"github.com/rymdhund/whazza/internal/persist"
+)
+
+// newestFirst makes results from statuses given oldest first, returned newest first
+func newestFirst(statuses ...string) []persist.ResultModel {
+	results := []persist.ResultModel{}
+	for i := len(statuses) - 1; i >= 0; i-- {
+		results = append(results, persist.ResultModel{Result: base.Result{Status: statuses[i]}})
+	}
+	return results
+}
+
+func TestFlapPercent(t *testing.T) {
+	if p := FlapPercent(newestFirst("good", "good", "good")); p != 0 {
+		t.Errorf("Expected 0%%, got %f", p)
+	}
+
+	alternating := []string{}
+	for i := 0; i < FlapWindow; i++ {
+		alternating = append(alternating, []string{"good", "fail"}[i%2])
+	}
+	if p := FlapPercent(newestFirst(alternating...)); p < 98 || p > 100 {
+		t.Errorf("Expected about 99%%, got %f", p)
+	}
+
+	// Recent changes weigh more than old ones
+	old := FlapPercent(newestFirst("fail", "good", "good", "good", "good", "good"))
+	recent := FlapPercent(newestFirst("good", "good", "good", "good", "good", "fail"))
+	if old >= recent {
+		t.Errorf("Expected old change %f to weigh less than recent change %f", old, recent)
+	}
+}
+
+func TestMaxFlapPercent(t *testing.T) {
+	statuses := []string{"good", "fail", "good", "fail", "good", "fail"}
+	for i := 0; i < 3*FlapWindow; i++ {
+		statuses = append(statuses, "good")
+	}
+	// Oldest first
+	results := newestFirst(statuses...)
+	for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+		results[i], results[j] = results[j], results[i]
+	}
+	if p := MaxFlapPercent(results); p < 20 {
+		t.Errorf("Expected the early flapping to be found, got %f", p)
+	}
+	if p := FlapPercent(newestFirst(statuses...)); p != 0 {
+		t.Errorf("Expected no flapping at the end, got %f", p)
+	}
+}
+
+func TestCheckStats(t *testing.T) {
+	check, _ := chk.New("http-up", "web", 60, []byte(`{"host": "example.com"}`))
+	from := time.Unix(1600000000, 0)
+	to := from.Add(24 * time.Hour)
+	res := func(status string, offset time.Duration) persist.ResultModel {
+		return persist.ResultModel{Result: base.Result{Status: status, Timestamp: from.Add(offset)}}
+	}
+
+	// Results every minute
+	results := []persist.ResultModel{res("good", -30*time.Second)}
+	for t := time.Minute; t < 24*time.Hour; t += time.Minute {
+		status := "good"
+		if t >= 6*time.Hour && t < 12*time.Hour {
+			status = "fail"
+		}
+		results = append(results, res(status, t))
+	}
+	a := CheckStats(check, results, from, to, false)
+	if a.Down != 6*time.Hour || a.Up != 18*time.Hour || a.Unknown != 0 {
+		t.Fatalf("Unexpected availability %+v", a)
+	}
+	if a.Percent() != 75 {
+		t.Fatalf("Expected 75%%, got %f", a.Percent())
+	}
+	if a.Incidents != 1 || a.MTTR() != 6*time.Hour || a.MTBF() != 18*time.Hour {
+		t.Fatalf("Unexpected incidents %+v", a)
+	}
+
+	// Expired time is unknown. The limit is 11 minutes for this check.
+	results = []persist.ResultModel{res("good", 0), res("good", time.Hour)}
+	a = CheckStats(check, results, from, from.Add(2*time.Hour), false)
+	if a.Up != 22*time.Minute || a.Unknown != 98*time.Minute {
+		t.Fatalf("Unexpected availability %+v", a)
+	}
+	if a.Percent() != 100 || a.Incidents != 0 {
+		t.Fatalf("Expected 100%% without incidents, got %+v", a)
+	}
+
+	// Unless expired time counts as down
+	a = CheckStats(check, results, from, from.Add(2*time.Hour), true)
+	if a.Up != 22*time.Minute || a.Down != 98*time.Minute || a.Incidents != 2 {
+		t.Fatalf("Unexpected availability %+v", a)
+	}
+
+	// Unknown time within an outage is the same incident
+	results = []persist.ResultModel{res("fail", -5*time.Minute), res("error", time.Hour), res("fail", 2*time.Hour), res("good", 3*time.Hour)}
+	a = CheckStats(check, results, from, from.Add(4*time.Hour), false)
+	if a.Incidents != 1 || a.Down != 17*time.Minute || a.Up != 11*time.Minute {
+		t.Fatalf("Unexpected availability %+v", a)
+	}
+}
+
+func TestBuildDigest(t *testing.T) {
+	db, err := persist.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveAgent("agent", ""); err != nil {
+		t.Fatal(err)
+	}
+	agent := persist.AgentModel{ID: 1, Name: "agent"}
+	web, _ := chk.New("http-up", "web", 60, []byte(`{"host": "example.com"}`))
+	cert, _ := chk.New("cert", "web", 3600, []byte(`{"host": "example.com"}`))
+	webModel, err := db.RegisterCheck(agent, web)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certModel, err := db.RegisterCheck(agent, cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	from := now.Add(-time.Hour)
+	for i := 0; i < 60; i++ {
+		status := "good"
+		if i >= 45 {
+			status = "fail"
+		}
+		_, err := db.AddResult(agent, webModel, base.Result{Status: status, Msg: "", Timestamp: from.Add(time.Duration(i) * time.Minute)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = db.AddResult(agent, certModel, base.Result{Status: "warning", Msg: "Cert expires in 12 days", Timestamp: from})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := BuildDigest(db, from, now, Options{FlapThreshold: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Failing) != 1 || len(d.Expired) != 0 || len(d.ExpiringCerts) != 1 || len(d.Flapping) != 0 {
+		t.Fatalf("Unexpected digest %+v", d)
+	}
+	if len(d.Namespaces) != 1 || d.Namespaces[0].Percent() != 87.5 {
+		t.Fatalf("Unexpected namespaces %+v", d.Namespaces)
+	}
+	text := d.Text()
+	if !strings.Contains(text, "web 87.50%") || !strings.Contains(text, "Cert expires in 12 days") {
+		t.Fatalf("Unexpected text:\n%s", text)
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	db, err := persist.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveAgent("agent", ""); err != nil {
+		t.Fatal(err)
+	}
+	agent := persist.AgentModel{ID: 1, Name: "agent"}
+	web, _ := chk.New("http-up", "web", 60, []byte(`{"host": "example.com"}`))
+	db1, _ := chk.New("tcp-port", "db", 60, []byte(`{"host": "example.com", "port": 5432}`))
+	webModel, err := db.RegisterCheck(agent, web)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbModel, err := db.RegisterCheck(agent, db1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from := time.Unix(1600000000, 0)
+	to := from.Add(time.Hour)
+	for i := 0; i < 60; i++ {
+		status := "good"
+		if i%20 >= 15 {
+			status = "fail"
+		}
+		ts := from.Add(time.Duration(i) * time.Minute)
+		if _, err := db.AddResult(agent, webModel, base.Result{Status: status, Timestamp: ts}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.AddResult(agent, dbModel, base.Result{Status: "good", Timestamp: ts}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := BuildReport(db, from, to, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Namespaces) != 2 || r.Namespaces[0].Namespace != "db" || r.Namespaces[1].Namespace != "web" {
+		t.Fatalf("Unexpected namespaces %+v", r.Namespaces)
+	}
+	ns := r.Namespaces[1]
+	if len(ns.Checks) != 1 || ns.Incidents != 3 || ns.Percent() != 75 || ns.MTTR() != 5*time.Minute || ns.MTBF() != 15*time.Minute {
+		t.Fatalf("Unexpected web stats %+v", ns)
+	}
+	if r.Namespaces[0].Percent() != 100 || r.Namespaces[0].Incidents != 0 {
+		t.Fatalf("Unexpected db stats %+v", r.Namespaces[0])
+	}
+	if text := r.Text(); !strings.Contains(text, "[web]  75.000% | 3 incidents | down 15m | MTTR 5m | MTBF 15m") {
+		t.Fatalf("Unexpected text:\n%s", text)
+	}
+}
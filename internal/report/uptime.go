@@ -0,0 +1,97 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rymdhund/whazza/internal/persist"
+	"github.com/rymdhund/whazza/internal/utils"
+)
+
+// Options for building reports
+type Options struct {
+	// Flap percent where a check counts as flapping, 0 disables flap detection
+	FlapThreshold float64
+	// Count expired time as down instead of unknown
+	ExpiredAsDown bool
+}
+
+type CheckReport struct {
+	Check persist.CheckModel
+	Stats
+}
+
+type NamespaceReport struct {
+	Namespace string
+	Stats
+	Checks []CheckReport
+}
+
+// Report is the uptime of all checks during a period, grouped by namespace
+type Report struct {
+	From       time.Time
+	To         time.Time
+	Namespaces []NamespaceReport
+}
+
+// BuildReport computes the uptime of each check and namespace for the period
+func BuildReport(db *persist.DB, from, to time.Time, opts Options) (Report, error) {
+	r := Report{From: from, To: to}
+	checks, err := db.GetChecks(true)
+	if err != nil {
+		return r, err
+	}
+	sort.Slice(checks, func(i, j int) bool {
+		ci, cj := checks[i].Check, checks[j].Check
+		if ci.Namespace != cj.Namespace {
+			return ci.Namespace < cj.Namespace
+		}
+		return ci.Title() < cj.Title()
+	})
+
+	for _, check := range checks {
+		// Retired checks count until they were retired
+		checkTo := to
+		if !check.Retired.IsZero() && check.Retired.Before(to) {
+			if !check.Retired.After(from) {
+				continue
+			}
+			checkTo = check.Retired
+		}
+		results, err := db.GetPeriodResults(check.ID, from, checkTo)
+		if err != nil {
+			return r, err
+		}
+		stats := CheckStats(check.Check, results, from, checkTo, opts.ExpiredAsDown)
+
+		if len(r.Namespaces) == 0 || r.Namespaces[len(r.Namespaces)-1].Namespace != check.Check.Namespace {
+			r.Namespaces = append(r.Namespaces, NamespaceReport{Namespace: check.Check.Namespace})
+		}
+		ns := &r.Namespaces[len(r.Namespaces)-1]
+		ns.Stats = ns.Stats.Add(stats)
+		ns.Checks = append(ns.Checks, CheckReport{check, stats})
+	}
+	return r, nil
+}
+
+func (s Stats) text() string {
+	return fmt.Sprintf("%7.3f%% | %d incidents | down %s | MTTR %s | MTBF %s",
+		s.Percent(), s.Incidents, utils.FormatDuration(s.Down), utils.FormatDuration(s.MTTR()), utils.FormatDuration(s.MTBF()))
+}
+
+func (r Report) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Uptime from %s to %s\n", r.From.Format("2006-01-02 15:04"), r.To.Format("2006-01-02 15:04"))
+	if len(r.Namespaces) == 0 {
+		b.WriteString("\nNo checks\n")
+	}
+	for _, ns := range r.Namespaces {
+		fmt.Fprintf(&b, "\n[%s] %s\n", ns.Namespace, ns.text())
+		for _, c := range ns.Checks {
+			fmt.Fprintf(&b, "  %s on %s | %s\n", c.Check.Check.Title(), c.Check.Agent.Name, c.text())
+		}
+	}
+	return b.String()
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"fmt"
 	"math"
 	"strconv"
 	"strings"
@@ -80,3 +81,55 @@ func s(x float64) string {
 	}
 	return "s"
 }
+
+// ParseDuration is like time.ParseDuration but also accepts days ("d") and weeks ("w"), eg "30d" or "1w2d"
+func ParseDuration(s string) (time.Duration, error) {
+	var total time.Duration
+	rest := s
+	for _, unit := range []struct {
+		suffix string
+		dur    time.Duration
+	}{{"w", 7 * 24 * time.Hour}, {"d", 24 * time.Hour}} {
+		if i := strings.Index(rest, unit.suffix); i >= 0 {
+			n, err := strconv.Atoi(rest[:i])
+			if err != nil {
+				return 0, fmt.Errorf("time: invalid duration %q", s)
+			}
+			total += time.Duration(n) * unit.dur
+			rest = rest[i+1:]
+		}
+	}
+	if rest == "" {
+		if s == "" {
+			return 0, fmt.Errorf("time: invalid duration %q", s)
+		}
+		return total, nil
+	}
+	d, err := time.ParseDuration(rest)
+	if err != nil {
+		return 0, fmt.Errorf("time: invalid duration %q", s)
+	}
+	return total + d, nil
+}
+
+// FormatDuration formats a duration rounded to minutes with days, eg "2d3h15m". Durations under a minute are "0m".
+func FormatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	minutes := d / time.Minute
+
+	text := ""
+	if days > 0 {
+		text += fmt.Sprintf("%dd", days)
+	}
+	if hours > 0 {
+		text += fmt.Sprintf("%dh", hours)
+	}
+	if minutes > 0 || text == "" {
+		text += fmt.Sprintf("%dm", minutes)
+	}
+	return text
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected time.Duration
+	}{
+		{"30d", 30 * 24 * time.Hour},
+		{"1w", 7 * 24 * time.Hour},
+		{"1w2d", 9 * 24 * time.Hour},
+		{"2d12h", 60 * time.Hour},
+		{"90m", 90 * time.Minute},
+	}
+	for _, c := range cases {
+		d, err := ParseDuration(c.input)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %s", c.input, err)
+		}
+		if d != c.expected {
+			t.Errorf("Wrong duration for %s: %s", c.input, d)
+		}
+	}
+
+	for _, input := range []string{"", "d", "xd", "10", "1d2"} {
+		if _, err := ParseDuration(input); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	cases := []struct {
+		input    time.Duration
+		expected string
+	}{
+		{0, "0m"},
+		{20 * time.Second, "0m"},
+		{90 * time.Minute, "1h30m"},
+		{50 * time.Hour, "2d2h"},
+		{24*time.Hour + 5*time.Minute, "1d5m"},
+	}
+	for _, c := range cases {
+		if s := FormatDuration(c.input); s != c.expected {
+			t.Errorf("Expected %s for %s, got %s", c.expected, c.input, s)
+		}
+	}
+}